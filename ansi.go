@@ -0,0 +1,120 @@
+package mud
+
+import "bytes"
+
+const esc = 0x1b
+
+// A Line is a line of output from the server, with a plain copy that has its
+// ANSI escape sequences removed for matching against patterns.
+type Line struct {
+	Raw   []byte
+	Plain []byte
+
+	// offsets[i] is the offset in Raw of Plain[i], and offsets[len(Plain)]
+	// is len(Raw).
+	offsets []int
+}
+
+// NewLine strips the ANSI escape sequences from raw.
+func NewLine(raw []byte) *Line {
+	l := &Line{
+		Raw:     raw,
+		Plain:   make([]byte, 0, len(raw)),
+		offsets: make([]int, 0, len(raw)+1),
+	}
+	for i := 0; i < len(raw); {
+		if raw[i] == esc {
+			i += escapeLen(raw[i:])
+			continue
+		}
+		l.Plain = append(l.Plain, raw[i])
+		l.offsets = append(l.offsets, i)
+		i++
+	}
+	l.offsets = append(l.offsets, len(raw))
+	return l
+}
+
+// span returns the part of Raw holding Plain[start:end]: from the first byte
+// of the span to just after the last, so that it includes escape sequences
+// within the span but not those around it.
+func (l *Line) span(start, end int) (int, int) {
+	if start >= end {
+		return l.offsets[start], l.offsets[start]
+	}
+	return l.offsets[start], l.offsets[end-1] + 1
+}
+
+// styledSpan is like span, but also includes the escape sequences leading up
+// to the span, which set its colors.
+func (l *Line) styledSpan(start, end int) (int, int) {
+	s, e := l.span(start, end)
+	if start < end {
+		s = 0
+		if start > 0 {
+			s = l.offsets[start-1] + 1
+		}
+	}
+	return s, e
+}
+
+// style returns the SGR (color and attribute) sequences in effect at offset i
+// of Raw, i.e. those since the last reset.
+func (l *Line) style(i int) []byte {
+	var style []byte
+	raw := l.Raw[:i]
+	for j := 0; j < len(raw); {
+		if raw[j] != esc {
+			j++
+			continue
+		}
+		n := escapeLen(raw[j:])
+		seq := raw[j : j+n]
+		j += n
+
+		if len(seq) < 3 || seq[1] != '[' || seq[len(seq)-1] != 'm' {
+			continue
+		}
+		params := seq[2 : len(seq)-1]
+		switch {
+		case len(params) == 0, bytes.Equal(params, []byte("0")):
+			style = style[:0]
+		case bytes.HasPrefix(params, []byte("0;")):
+			style = append(style[:0], seq...)
+		default:
+			style = append(style, seq...)
+		}
+	}
+	return style
+}
+
+// escapeLen returns the length of the escape sequence at the start of b,
+// which must begin with ESC. An unterminated sequence runs to the end of b.
+func escapeLen(b []byte) int {
+	if len(b) < 2 {
+		return len(b)
+	}
+	switch b[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(b)
+	case ']':
+		// OSC: terminated by BEL or ST (ESC \)
+		for i := 2; i < len(b); i++ {
+			if b[i] == '\a' {
+				return i + 1
+			}
+			if b[i] == esc && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(b)
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,130 @@
+package mud
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestNewLine(t *testing.T) {
+	tests := map[string]struct {
+		raw   string
+		plain string
+	}{
+		"plain":        {raw: "You are hungry.", plain: "You are hungry."},
+		"colored word": {raw: "You are \x1b[1;33mhungry\x1b[0m.", plain: "You are hungry."},
+		"reset":        {raw: "\x1b[mYou\x1b[0m", plain: "You"},
+		"cursor":       {raw: "\x1b[2K\x1b[1Gdone", plain: "done"},
+		"osc":          {raw: "\x1b]0;title\aok", plain: "ok"},
+		"unterminated": {raw: "ok\x1b[3", plain: "ok"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := string(NewLine([]byte(test.raw)).Plain); got != test.plain {
+				t.Errorf("got %q, want %q", got, test.plain)
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	red := &Color{color.New(color.FgRed)}
+	red.EnableColor()
+
+	tests := map[string]struct {
+		pattern Pattern
+		line    string
+		result  string
+	}{
+		"plain": {
+			pattern: "hungry",
+			line:    "You are hungry.",
+			result:  "You are \x1b[31mhungry\x1b[0m.",
+		},
+		"across escapes": {
+			pattern: "are hungry",
+			line:    "You are \x1b[33mhungry\x1b[0m.",
+			result:  "You \x1b[31mare hungry\x1b[0m\x1b[33m\x1b[0m.",
+		},
+		"server color restored": {
+			pattern: "Bob",
+			line:    "\x1b[32mBob says hi\x1b[0m",
+			result:  "\x1b[32m\x1b[31mBob\x1b[0m\x1b[32m says hi\x1b[0m",
+		},
+		"no match": {
+			pattern: "thirsty",
+			line:    "You are \x1b[33mhungry\x1b[0m.",
+			result:  "You are \x1b[33mhungry\x1b[0m.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := string(test.pattern.Color([]byte(test.line), red)); got != test.result {
+				t.Errorf("got %q, want %q", got, test.result)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := map[string]struct {
+		pattern Pattern
+		with    string
+		line    string
+		result  string
+		ok      bool
+	}{
+		"plain": {
+			pattern: "shield disappears",
+			with:    "< SHIELD OFF >",
+			line:    "Your shield disappears.",
+			result:  "< SHIELD OFF >",
+			ok:      true,
+		},
+		"server color kept": {
+			pattern: "(\\w+) shield disappears",
+			with:    "< $1 SHIELD OFF >",
+			line:    "\x1b[1;36mYour\x1b[0m \x1b[33mshield disappears.\x1b[0m",
+			result:  "\x1b[1;36m< Your SHIELD OFF >\x1b[0m",
+			ok:      true,
+		},
+		"no match": {
+			pattern: "shield disappears",
+			line:    "You are hungry.",
+			result:  "You are hungry.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := test.pattern.Replace([]byte(test.line), test.with, nil)
+			if string(got) != test.result || ok != test.ok {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, test.result, test.ok)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := map[string]struct {
+		raw  string
+		html string
+	}{
+		"plain":     {raw: "a < b", html: "a &lt; b"},
+		"color":     {raw: "\x1b[31mred\x1b[0m text", html: `<span style="color: #cd0000">red</span> text`},
+		"bold":      {raw: "\x1b[1;92mhi", html: `<span style="color: #00ff00; font-weight: bold">hi</span>`},
+		"256 color": {raw: "\x1b[38;5;196mhot", html: `<span style="color: #ff0000">hot</span>`},
+		"truecolor": {raw: "\x1b[48;2;1;2;3mbg", html: `<span style="background: #010203">bg</span>`},
+		"reset":     {raw: "\x1b[4mu\x1b[mn", html: `<span style="text-decoration: underline">u</span>n`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := NewLine([]byte(test.raw)).HTML(); got != test.html {
+				t.Errorf("got %q, want %q", got, test.html)
+			}
+		})
+	}
+}
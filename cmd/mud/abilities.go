@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// updateAbilities marks abilities ready or cooling down when line matches
+// one of their patterns, reports changes to the ability log and runs any
+// commands that were waiting for an ability to be ready.
+func (c *Session) updateAbilities(line []byte) {
+	c.Lock()
+	var changes []string
+	var cmds []string
+	for name, ability := range c.cfg.Abilities {
+		name = strings.ToLower(name)
+		was := c.abilities[name]
+		ready := was
+		for _, pattern := range ability.Ready {
+			if pattern.Match(line) {
+				ready = true
+			}
+		}
+		for _, pattern := range ability.Wait {
+			if pattern.Match(line) {
+				ready = false
+			}
+		}
+		if ready == was {
+			continue
+		}
+		c.abilities[name] = ready
+		changes = append(changes, abilityStatus(name, ready))
+
+		if ready {
+			for _, cmd := range c.whenReady[name] {
+				info.Fprintf(c.output, "[ready: %s: %s]\n", name, cmd)
+				cmds = append(cmds, c.expand(cmd)...)
+			}
+			delete(c.whenReady, name)
+		}
+	}
+	logfile := c.cfg.AbilityLog
+	c.Unlock()
+
+	if len(changes) > 0 && logfile != "" {
+		if err := c.logAbilities(logfile, changes); err != nil {
+			info.Fprintf(c.output, "[ERROR: %v]\n", err)
+		}
+	}
+
+	for _, sub := range cmds {
+		if !c.command(sub) {
+			fmt.Fprintln(c.conn, sub)
+		}
+	}
+}
+
+func abilityStatus(name string, ready bool) string {
+	if ready {
+		return name + " up"
+	}
+	return name + " down"
+}
+
+// logAbilities appends status lines to the ability log, in the format read
+// by cmd/status.
+func (c *Session) logAbilities(filename string, lines []string) error {
+	f, err := os.OpenFile(filepath.Join(c.path, filename), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Fprintln(f, line)
+	}
+	return f.Close()
+}
+
+// lookupAbility returns "up" or "down" for a configured ability. Abilities
+// are assumed to be ready until output shows otherwise.
+func lookupAbility(abilities map[string]bool, name string) (string, bool) {
+	ready, ok := abilities[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	if ready {
+		return "up", true
+	}
+	return "down", true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnjackins/mud"
+)
+
+func TestAbilities(t *testing.T) {
+	config := `
+abilities:
+  bash:
+    ready: ['You feel ready to bash again\.']
+    wait: ['You slam into', 'You fall over']
+ability_log: status.log
+`
+	tests := map[string]struct {
+		// lines from the server, or commands if they start with "/"
+		input  []string
+		status string // $ability.bash
+		log    string
+		out    string
+	}{
+		"ready at first": {
+			status: "up",
+		},
+		"cooling down": {
+			input:  []string{"You slam into a goblin."},
+			status: "down",
+			log:    "bash down\n",
+			out:    "You slam into a goblin.\n",
+		},
+		"ready again": {
+			input:  []string{"You fall over.", "You feel ready to bash again."},
+			status: "up",
+			log:    "bash down\nbash up\n",
+			out:    "You fall over.\nYou feel ready to bash again.\n",
+		},
+		"unchanged": {
+			input:  []string{"You feel ready to bash again."},
+			status: "up",
+			out:    "You feel ready to bash again.\n",
+		},
+		"onready when ready": {
+			input:  []string{"/onready bash {bash $target}"},
+			status: "up",
+			out:    "> bash orc\n",
+		},
+		"onready when cooling down": {
+			input: []string{
+				"You slam into a goblin.",
+				"/onready Bash {bash $target}",
+				"You feel ready to bash again.",
+			},
+			status: "up",
+			log:    "bash down\nbash up\n",
+			out: "You slam into a goblin.\n" +
+				"You feel ready to bash again.\n" +
+				"[ready: bash: bash $target]\n" +
+				"> bash orc\n",
+		},
+		"onready unknown": {
+			input:  []string{"/onready kick kick"},
+			status: "up",
+			out:    "onready: unknown ability \"kick\"\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sess, out := newTestSession(t, config)
+			sess.vars["target"] = "orc"
+			for _, s := range test.input {
+				if strings.HasPrefix(s, "/") {
+					sess.command(s)
+				} else {
+					sess.handleLine([]byte(s), true, func(*mud.Line) {})
+				}
+			}
+
+			sess.RLock()
+			status, _ := sess.env().Get("ability.bash")
+			sess.RUnlock()
+			if status != test.status {
+				t.Errorf("got $ability.bash %q, want %q", status, test.status)
+			}
+			log, _ := ioutil.ReadFile(filepath.Join(sess.path, "status.log"))
+			if diff := cmp.Diff(test.log, string(log)); diff != "" {
+				t.Errorf("ability log mismatch: %v", diff)
+			}
+			if diff := cmp.Diff(test.out, out.String()); diff != "" {
+				t.Errorf("output mismatch: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jnjackins/mud"
+	"github.com/jnjackins/mud/internal/interpolate"
+	"gopkg.in/yaml.v2"
+)
+
+// checkConfigs checks the configuration in each session directory or config
+// file in paths, writing diagnostics to w. It reports whether all of them
+// were free of errors; warnings don't count.
+func checkConfigs(w io.Writer, paths []string) bool {
+	ok := true
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			path = filepath.Join(path, "config.yaml")
+		}
+		c := &checker{file: path}
+		c.check()
+		c.print(w)
+		if c.errors > 0 {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// A checker checks a config file.
+type checker struct {
+	file        string
+	lines       []string
+	diagnostics []diagnostic
+	errors      int
+}
+
+// A diagnostic is an error or warning about a line of a config file, or
+// about the whole file if line is 0.
+type diagnostic struct {
+	line int
+	msg  string
+}
+
+func (c *checker) check() {
+	buf, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		c.errorf(0, "%v", err)
+		return
+	}
+	c.lines = strings.Split(string(buf), "\n")
+
+	cfg, err := mud.UnmarshalConfigStrict(c.file)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			c.yamlError(msg)
+		}
+		// If those were only unknown keys, the rest of the file can still
+		// be checked.
+		cfg, err = mud.UnmarshalConfig(c.file)
+		if errors.As(err, &typeErr) {
+			return
+		}
+	}
+	var cfgErrs mud.ConfigErrors
+	switch {
+	case errors.As(err, &cfgErrs):
+		for _, err := range cfgErrs {
+			var perr *mud.PatternError
+			if errors.As(err, &perr) {
+				c.errorf(c.find(string(perr.Pattern)), "%v", err)
+			} else {
+				c.errorf(c.find(quoted(err.Error())), "%v", err)
+			}
+		}
+	case err != nil:
+		c.yamlError(err.Error())
+		return
+	}
+
+	if _, err := telnetConfig(cfg); err != nil {
+		c.errorf(c.find(quoted(err.Error())), "%v", err)
+	}
+	for name, log := range cfg.Log {
+		if err := checkLogColor(log.Color); err != nil {
+			c.errorf(c.findKey(name), "log %s: %v", name, err)
+		}
+	}
+	for name, alias := range cfg.Aliases {
+		if _, err := interpolate.NewParser(alias.Do).Parse(); err != nil {
+			c.errorf(c.findKey(name), "aliases: %s: %v", name, err)
+		}
+	}
+	for _, t := range cfg.Timers {
+		if t.Every <= 0 {
+			c.errorf(c.find(t.Do), "timers: %q: every must be a positive duration", t.Do)
+		}
+	}
+
+	for name, ability := range cfg.Abilities {
+		if len(ability.Ready) == 0 && len(ability.Wait) == 0 {
+			c.warnf(c.findKey(name), "abilities: %s has no ready or wait patterns, so it's always up", name)
+		}
+	}
+	for name, d := range cfg.Dump {
+		switch {
+		case d == nil || d.Cmd == "":
+			c.warnf(c.findKey(name), "dump: %s has no cmd, so it captures nothing", name)
+		case len(d.Match) == 0:
+			c.warnf(c.findKey(name), "dump: %s has no match patterns, so it writes an empty file", name)
+		}
+	}
+}
+
+// yamlLine matches the line number in a YAML error.
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line ([0-9]+): (.*)$`)
+
+// unknownField matches the error for an unknown key in strict mode.
+var unknownField = regexp.MustCompile(`^field (\S+) not found in type `)
+
+// yamlError reports an error from the YAML decoder, which may begin with
+// the line it is on.
+func (c *checker) yamlError(msg string) {
+	m := yamlLine.FindStringSubmatch(msg)
+	if m == nil {
+		c.errorf(c.find(quoted(msg)), "%s", msg)
+		return
+	}
+	line, _ := strconv.Atoi(m[1])
+	msg = m[2]
+	if m := unknownField.FindStringSubmatch(msg); m != nil {
+		// the type is often an unhelpful anonymous struct
+		msg = fmt.Sprintf("unknown key %q", m[1])
+	}
+	c.errorf(line, "%s", msg)
+}
+
+func (c *checker) errorf(line int, format string, args ...interface{}) {
+	c.errors++
+	c.diagnostics = append(c.diagnostics, diagnostic{line: line, msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) warnf(line int, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic{line: line, msg: "warning: " + fmt.Sprintf(format, args...)})
+}
+
+// print writes the diagnostics to w in the order of the lines they are
+// about.
+func (c *checker) print(w io.Writer) {
+	sort.Slice(c.diagnostics, func(i, j int) bool {
+		a, b := c.diagnostics[i], c.diagnostics[j]
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.msg < b.msg
+	})
+	for _, d := range c.diagnostics {
+		if d.line > 0 {
+			fmt.Fprintf(w, "%s:%d: %s\n", c.file, d.line, d.msg)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", c.file, d.msg)
+		}
+	}
+}
+
+// find returns the number of the first line containing s outside a comment,
+// or 0 if there is none.
+func (c *checker) find(s string) int {
+	if s == "" {
+		return 0
+	}
+	for i, line := range c.lines {
+		if j := strings.Index(line, "#"); j >= 0 && !strings.Contains(s, "#") {
+			line = line[:j]
+		}
+		if strings.Contains(line, s) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// findKey returns the number of the first line with the mapping key, or 0 if
+// there is none.
+func (c *checker) findKey(key string) int {
+	for i, line := range c.lines {
+		line = strings.Trim(strings.TrimSpace(line), `'"`)
+		if strings.HasPrefix(line, key+":") || strings.HasPrefix(line, key+`":`) || strings.HasPrefix(line, key+`':`) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// quotedValue matches a quoted value in an error message.
+var quotedValue = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+// quoted returns the first value quoted in msg, which is likely to appear
+// in the config file.
+func quoted(msg string) string {
+	m := quotedValue.FindString(msg)
+	if m == "" {
+		return ""
+	}
+	s, err := strconv.Unquote(m)
+	if err != nil {
+		return ""
+	}
+	return s
+}
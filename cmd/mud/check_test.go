@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigs(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   []string
+		ok     bool
+	}{
+		"valid": {
+			config: "address: example.com:4000\ntriggers:\n  You are hungry: eat bread\n",
+			ok:     true,
+		},
+		"unknown key": {
+			config: "address: example.com:4000\nlog:\n  chat:\n    colour: keep\n",
+			want:   []string{`4: unknown key "colour"`},
+		},
+		"unknown key and more": {
+			config: "triggers:\n  '(foo': bar\nlog:\n  chat:\n    colour: keep\ntimers:\n  - every: 0s\n    do: dance\n",
+			want: []string{
+				`2: triggers: bad pattern "(foo": missing closing )`,
+				`5: unknown key "colour"`,
+				`8: timers: "dance": every must be a positive duration`,
+			},
+		},
+		"bad duration": {
+			config: "timers:\n  - every: 1 minute\n    do: dance\n",
+			want:   []string{"2: cannot unmarshal !!str `1 minute` into time.Duration"},
+		},
+		"bad color": {
+			config: "highlight:\n  YOU: pink\n",
+			want:   []string{`2: unknown color "pink"`},
+		},
+		"dump outside session": {
+			config: "dump:\n  skills:\n    cmd: skills\n    dest: /tmp/skills\n    match:\n      '(.*)': $1\n",
+			want:   []string{`4: dump skills: "/tmp/skills" is outside the session directory`},
+		},
+		"missing color": {
+			config: "highlight:\n  - match: YOU\n    group: combat\n",
+			want:   []string{`2: highlight: "YOU" has no color`},
+		},
+		"problems": {
+			config: `prompt_detection: fast
+triggers:
+  '(foo': bar
+aliases:
+  k: kill ${1
+abilities:
+  bash: {}
+dump:
+  skills:
+    cmd: skills
+timers:
+  - every: 0s
+    do: dance
+`,
+			want: []string{
+				`1: unknown prompt_detection "fast"`,
+				`3: triggers: bad pattern "(foo": missing closing )`,
+				`5: aliases: k: Expected identifier to start with a letter, got 1`,
+				`7: warning: abilities: bash has no ready or wait patterns, so it's always up`,
+				`9: warning: dump: skills has no match patterns, so it writes an empty file`,
+				`13: timers: "dance": every must be a positive duration`,
+			},
+		},
+		"only warnings": {
+			config: "dump:\n  skills: {}\n",
+			want:   []string{"2: warning: dump: skills has no cmd, so it captures nothing"},
+			ok:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if ok := checkConfigs(&buf, []string{filepath.Dir(path)}); ok != test.ok {
+				t.Errorf("got ok %v, want %v", ok, test.ok)
+			}
+			var want string
+			for _, line := range test.want {
+				want += path + ":" + line + "\n"
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, strings.TrimSuffix(want, "\n"))
+			}
+		})
+	}
+}
@@ -10,18 +10,38 @@ import (
 	"github.com/jnjackins/mud/internal/interpolate"
 )
 
-var commands = map[string]func(*Session, ...string){
-	"/on":            on,
-	"/set":           set,
-	"/incr":          incr,
-	"/vars":          vars,
-	"/list":          list,
-	"/aliases":       aliases,
-	"/wait":          wait,
-	"/triggers-off":  disableTriggers,
-	"/triggers-on":   enableTriggers,
-	"/history":       history,
-	"/clear-history": clearHistory,
+var commands map[string]func(*Session, ...string)
+
+// commands is initialized in init, since some of them run other commands.
+func init() {
+	commands = map[string]func(*Session, ...string){
+		"/on":            on,
+		"/ongmcp":        onGMCP,
+		"/onready":       onReady,
+		"/set":           set,
+		"/incr":          incr,
+		"/vars":          vars,
+		"/list":          list,
+		"/aliases":       aliases,
+		"/wait":          wait,
+		"/triggers":      listTriggers,
+		"/triggers-off":  disableTriggers,
+		"/triggers-on":   enableTriggers,
+		"/enable":        enableGroup,
+		"/disable":       disableGroup,
+		"/groups":        listGroups,
+		"/history":       history,
+		"/clear-history": clearHistory,
+		"/gmcp":          gmcp,
+		"/msdp":          msdp,
+		"/naws":          naws,
+		"/dump":          dumpCmd,
+		"/disconnect":    disconnect,
+		"/reconnect":     reconnect,
+		"/sessions":      sessions,
+		"/connect":       connectSession,
+		"/close":         closeSession,
+	}
 }
 
 func on(c *Session, args ...string) {
@@ -29,12 +49,66 @@ func on(c *Session, args ...string) {
 		fmt.Fprintf(c.output, "on: usage: /on {pattern} {action}\n")
 		return
 	}
-	on := mud.Pattern(args[0])
+	on := mud.Trigger{Pattern: mud.Pattern(args[0]), Do: args[1]}
+	if _, err := on.Pattern.Regexp(); err != nil {
+		fmt.Fprintf(c.output, "on: %v\n", err)
+		return
+	}
 	c.Lock()
-	c.oneTimeTriggers[on] = args[1]
+	c.oneTimeTriggers = append(c.oneTimeTriggers, on)
 	c.Unlock()
 }
 
+func onGMCP(c *Session, args ...string) {
+	if len(args) != 2 && len(args) != 3 {
+		fmt.Fprintf(c.output, "ongmcp: usage: /ongmcp {package} [{condition}] {action}\n")
+		return
+	}
+	t := mud.GMCPTrigger{
+		Package: args[0],
+		Do:      args[len(args)-1],
+	}
+	if len(args) == 3 {
+		cond, err := mud.ParseCondition(args[1])
+		if err != nil {
+			fmt.Fprintf(c.output, "ongmcp: %v\n", err)
+			return
+		}
+		t.If = &cond
+	}
+	c.Lock()
+	c.oneTimeGMCP = append(c.oneTimeGMCP, t)
+	c.Unlock()
+}
+
+func onReady(c *Session, args ...string) {
+	if len(args) != 2 {
+		fmt.Fprintf(c.output, "onready: usage: /onready {ability} {action}\n")
+		return
+	}
+	name := strings.ToLower(args[0])
+	c.Lock()
+	ready, ok := c.abilities[name]
+	if ok && !ready {
+		c.whenReady[name] = append(c.whenReady[name], args[1])
+	}
+	c.Unlock()
+
+	switch {
+	case !ok:
+		fmt.Fprintf(c.output, "onready: unknown ability %q\n", args[0])
+	case ready:
+		c.RLock()
+		cmds := c.expand(args[1])
+		c.RUnlock()
+		for _, sub := range cmds {
+			if !c.command(sub) {
+				fmt.Fprintln(c.conn, sub)
+			}
+		}
+	}
+}
+
 func set(c *Session, args ...string) {
 	for _, arg := range args {
 		parts := strings.Split(arg, "=")
@@ -114,7 +188,9 @@ func list(c *Session, args ...string) {
 		list := c.lists[name]
 		c.RUnlock()
 
-		interpolated, err := interpolate.Interpolate(c.vars, nil, args[2])
+		c.RLock()
+		interpolated, err := interpolate.Interpolate(c.env(), nil, args[2])
+		c.RUnlock()
 		if err != nil {
 			fmt.Fprintf(c.output, "failed to interpolate %q\n", args[2])
 		}
@@ -175,8 +251,8 @@ func list(c *Session, args ...string) {
 
 func aliases(c *Session, args ...string) {
 	c.RLock()
-	for name, val := range c.cfg.Aliases {
-		fmt.Fprintf(c.output, "%s=%s\n", name, val)
+	for name, alias := range c.cfg.Aliases {
+		fmt.Fprintf(c.output, "%s=%s\n", name, alias.Do)
 	}
 	c.RUnlock()
 }
@@ -197,6 +273,61 @@ func wait(c *Session, args ...string) {
 	time.Sleep(d)
 }
 
+func listTriggers(c *Session, args ...string) {
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, section := range []struct {
+		name     string
+		triggers mud.Triggers
+	}{
+		{"triggers", c.cfg.Triggers},
+		{"prompt triggers", c.cfg.PromptTriggers},
+		{"one-time triggers", c.oneTimeTriggers},
+	} {
+		if len(section.triggers) == 0 {
+			continue
+		}
+		fmt.Fprintf(c.output, "%s:\n", section.name)
+		for _, t := range section.triggers {
+			fmt.Fprintf(c.output, "  %s\n", describeTrigger(t))
+		}
+	}
+}
+
+// describeTrigger returns a line describing t, including the kind of each of
+// its patterns.
+func describeTrigger(t mud.Trigger) string {
+	var patterns []string
+	for _, p := range append([]mud.Pattern{t.Pattern}, t.Lines...) {
+		if p != "" {
+			patterns = append(patterns, p.Kind()+":"+p.Text())
+		}
+	}
+	s := strings.Join(patterns, " / ") + " => " + t.Do
+
+	var opts []string
+	if t.Within != 0 {
+		opts = append(opts, fmt.Sprintf("within %d", t.Within))
+	}
+	if t.Priority != 0 {
+		opts = append(opts, fmt.Sprintf("priority %d", t.Priority))
+	}
+	if t.Stop {
+		opts = append(opts, "stop")
+	}
+	if t.Group != "" {
+		opts = append(opts, "group "+t.Group)
+	}
+	if t.Assign.All || len(t.Assign.Groups) > 0 {
+		opts = append(opts, "assign")
+	}
+	if len(opts) > 0 {
+		s += " (" + strings.Join(opts, ", ") + ")"
+	}
+	return s
+}
+
 func disableTriggers(c *Session, args ...string) {
 	c.Lock()
 	c.triggersDisabled = true
@@ -209,6 +340,30 @@ func enableTriggers(c *Session, args ...string) {
 	c.Unlock()
 }
 
+func enableGroup(c *Session, args ...string) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.output, "enable: usage: /enable group\n")
+		return
+	}
+	if err := c.setGroup(args[0], true); err != nil {
+		fmt.Fprintf(c.output, "enable: %v\n", err)
+	}
+}
+
+func disableGroup(c *Session, args ...string) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.output, "disable: usage: /disable group\n")
+		return
+	}
+	if err := c.setGroup(args[0], false); err != nil {
+		fmt.Fprintf(c.output, "disable: %v\n", err)
+	}
+}
+
+func listGroups(c *Session, args ...string) {
+	c.listGroups()
+}
+
 func history(c *Session, args ...string) {
 	fmt.Fprintf(c.output, "%s\n", strings.Join(c.history, "; "))
 }
@@ -216,3 +371,169 @@ func history(c *Session, args ...string) {
 func clearHistory(c *Session, args ...string) {
 	c.history = []string{}
 }
+
+func gmcp(c *Session, args ...string) {
+	if len(args) == 0 || len(args) == 1 && (args[0] == "subscribe" || args[0] == "unsubscribe") {
+		fmt.Fprintf(c.output, "gmcp: usage: /gmcp subscribe|unsubscribe <module> ...\n")
+		return
+	}
+
+	conn, err := c.conn.get()
+	if err != nil {
+		fmt.Fprintf(c.output, "gmcp: %v\n", err)
+		return
+	}
+
+	switch args[0] {
+	case "subscribe":
+		err = conn.GMCPSubscribe(gmcpModules(args[1:])...)
+	case "unsubscribe":
+		err = conn.GMCPUnsubscribe(gmcpModules(args[1:])...)
+	case "supports":
+		fmt.Fprintln(c.output, strings.Join(conn.GMCPSupports(), ", "))
+	default:
+		fmt.Fprintf(c.output, "gmcp: unknown command %q\n", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(c.output, "gmcp: %v\n", err)
+	}
+}
+
+// gmcpModules groups arguments into module names with optional versions, so
+// that both "/gmcp subscribe Room 1 Char" and "/gmcp subscribe {Room 1}"
+// work.
+func gmcpModules(args []string) []string {
+	var modules []string
+	for _, arg := range args {
+		if _, err := strconv.Atoi(arg); err == nil && len(modules) > 0 {
+			modules[len(modules)-1] += " " + arg
+			continue
+		}
+		modules = append(modules, arg)
+	}
+	return modules
+}
+
+func msdp(c *Session, args ...string) {
+	if len(args) < 2 {
+		fmt.Fprintf(c.output, "msdp: usage: /msdp report|unreport|send <variable> ...\n")
+		return
+	}
+
+	conn, err := c.conn.get()
+	if err != nil {
+		fmt.Fprintf(c.output, "msdp: %v\n", err)
+		return
+	}
+
+	switch args[0] {
+	case "report":
+		err = conn.MSDPReport(args[1:]...)
+	case "unreport":
+		err = conn.MSDPUnreport(args[1:]...)
+	case "send":
+		err = conn.MSDPSend(args[1:]...)
+	default:
+		fmt.Fprintf(c.output, "msdp: unknown command %q\n", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(c.output, "msdp: %v\n", err)
+	}
+}
+
+func naws(c *Session, args ...string) {
+	if len(args) != 2 {
+		fmt.Fprintf(c.output, "naws: usage: /naws <width> <height>\n")
+		return
+	}
+	width, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Fprintf(c.output, "failed to parse integer: %v\n", args[0])
+		return
+	}
+	height, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Fprintf(c.output, "failed to parse integer: %v\n", args[1])
+		return
+	}
+	c.Lock()
+	c.width, c.height = width, height
+	c.Unlock()
+
+	// the size is sent on the next connection if there is none now
+	conn, err := c.conn.get()
+	if err != nil {
+		return
+	}
+	if err := conn.SetWindowSize(width, height); err != nil {
+		fmt.Fprintf(c.output, "naws: %v\n", err)
+	}
+}
+
+func dumpCmd(c *Session, args ...string) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.output, "dump: usage: /dump <name>\n")
+		return
+	}
+	if err := c.startDump(args[0]); err != nil {
+		fmt.Fprintf(c.output, "dump: %v\n", err)
+	}
+}
+
+func disconnect(c *Session, args ...string) {
+	c.Lock()
+	c.stayDisconnected = true
+	c.Unlock()
+
+	// drop the current connection, if any, and stop waiting to reconnect
+	c.conn.close()
+	c.wake()
+}
+
+func reconnect(c *Session, args ...string) {
+	c.Lock()
+	c.stayDisconnected = false
+	c.Unlock()
+
+	// drop the current connection, if any, and skip the backoff
+	c.conn.close()
+	c.wake()
+}
+
+func sessions(c *Session, args ...string) {
+	if c.client == nil {
+		return
+	}
+	c.client.listSessions(c.output)
+}
+
+func connectSession(c *Session, args ...string) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.output, "connect: usage: /connect prefix:path\n")
+		return
+	}
+	if c.client == nil {
+		return
+	}
+	prefix, path, err := parseSession(args[0])
+	if err != nil {
+		fmt.Fprintf(c.output, "connect: %v\n", err)
+		return
+	}
+	if _, err := c.client.startSession(prefix, path); err != nil {
+		fmt.Fprintf(c.output, "connect: %v\n", err)
+	}
+}
+
+func closeSession(c *Session, args ...string) {
+	if len(args) != 1 {
+		fmt.Fprintf(c.output, "close: usage: /close prefix\n")
+		return
+	}
+	if c.client == nil {
+		return
+	}
+	if err := c.client.closeSession(args[0]); err != nil {
+		fmt.Fprintf(c.output, "close: %v\n", err)
+	}
+}
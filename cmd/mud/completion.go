@@ -29,7 +29,9 @@ func (sess *Session) startCompleter() {
 					time.Sleep(500 * time.Millisecond)
 					continue
 				}
-				log.Printf("completer: %v", err)
+				if !sess.isClosed() {
+					log.Printf("completer: %v", err)
+				}
 				break
 			}
 
@@ -92,12 +94,12 @@ func (sess *Session) complete(line string, pos int) (head string, completions []
 		// otherwise, complete with aliases / abilities
 		sess.Lock()
 		cfg := sess.cfg
-		sess.Unlock()
-		for s := range cfg.Aliases {
-			if strings.HasPrefix(s, partial) {
+		for s, alias := range cfg.Aliases {
+			if strings.HasPrefix(s, partial) && sess.groupEnabled(alias.Group) {
 				completions = append(completions, s)
 			}
 		}
+		sess.Unlock()
 		for s := range cfg.Abilities {
 			if strings.HasPrefix(s, partial) {
 				completions = append(completions, s)
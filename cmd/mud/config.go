@@ -1,6 +1,12 @@
 package main
 
-import "github.com/jnjackins/mud"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jnjackins/mud"
+	"github.com/jnjackins/mud/telnet"
+)
 
 func (c *Session) SetConfig(cfg mud.Config) {
 	c.Lock()
@@ -14,6 +20,14 @@ func (c *Session) SetConfig(cfg mud.Config) {
 		}
 	}
 
+	// abilities are assumed to be ready until output shows otherwise
+	for name := range cfg.Abilities {
+		name = strings.ToLower(name)
+		if _, exists := c.abilities[name]; !exists {
+			c.abilities[name] = true
+		}
+	}
+
 	for k, v := range cfg.Lists {
 		if _, exists := c.lists[k]; !exists {
 			c.lists[k] = v
@@ -25,3 +39,70 @@ func (c *Session) SetConfig(cfg mud.Config) {
 	}
 	c.cancelTimers = c.startTimers()
 }
+
+// mttsFlags are the names of terminal capabilities in the terminal.mtts
+// config section.
+var mttsFlags = map[string]telnet.MTTS{
+	"ansi":              telnet.MTTSANSI,
+	"vt100":             telnet.MTTSVT100,
+	"utf-8":             telnet.MTTSUTF8,
+	"256-colors":        telnet.MTTS256Colors,
+	"mouse-tracking":    telnet.MTTSMouseTracking,
+	"osc-color-palette": telnet.MTTSOSCColorPalette,
+	"screen-reader":     telnet.MTTSScreenReader,
+	"proxy":             telnet.MTTSProxy,
+	"truecolor":         telnet.MTTSTrueColor,
+	"mnes":              telnet.MTTSMNES,
+	"mslp":              telnet.MTTSMSLP,
+	"ssl":               telnet.MTTSSSL,
+}
+
+// telnetConfig returns the telnet negotiation settings for cfg.
+func telnetConfig(cfg mud.Config) (*telnet.Config, error) {
+	tc := &telnet.Config{
+		Client:       cfg.GMCP.Client,
+		Version:      cfg.GMCP.Version,
+		GMCPSupports: cfg.GMCP.Supports,
+		MSDPReport:   cfg.MSDP.Report,
+		Width:        cfg.Terminal.Width,
+		Height:       cfg.Terminal.Height,
+		TerminalType: cfg.Terminal.Type,
+
+		PromptTimeout: cfg.PromptTimeout,
+	}
+
+	detection := cfg.PromptDetection
+	if detection == "" {
+		detection = "timeout"
+		if cfg.Prompt != "" {
+			detection = "regex"
+		}
+	}
+	switch detection {
+	case "ga":
+		tc.Prompt = telnet.PromptGA
+	case "timeout":
+		tc.Prompt = telnet.PromptTimeout
+	case "regex":
+		if cfg.Prompt == "" {
+			return nil, fmt.Errorf("prompt_detection regex needs a prompt pattern")
+		}
+		re, err := cfg.Prompt.Regexp()
+		if err != nil {
+			return nil, fmt.Errorf("prompt: %v", err)
+		}
+		tc.Prompt = telnet.PromptRegexp
+		tc.PromptPattern = re
+	default:
+		return nil, fmt.Errorf("unknown prompt_detection %q", cfg.PromptDetection)
+	}
+
+	for _, name := range cfg.Terminal.MTTS {
+		flag, ok := mttsFlags[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown terminal capability %q", name)
+		}
+		tc.MTTS |= flag
+	}
+	return tc, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jnjackins/mud/telnet"
+)
+
+var errNotConnected = errors.New("not connected")
+
+// A link is a session's connection to the mud server. The connection is
+// replaced when the session reconnects, and writes fail while there is none.
+type link struct {
+	sync.Mutex
+	conn *telnet.Conn
+
+	// record, if set, gets what would be written to the server instead,
+	// for mud test.
+	record io.Writer
+}
+
+func (l *link) Write(b []byte) (int, error) {
+	if l.record != nil {
+		return l.record.Write(b)
+	}
+	conn, err := l.get()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Write(b)
+}
+
+// get returns the current connection, or errNotConnected.
+func (l *link) get() (*telnet.Conn, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.conn == nil {
+		return nil, errNotConnected
+	}
+	return l.conn, nil
+}
+
+func (l *link) set(conn *telnet.Conn) {
+	l.Lock()
+	l.conn = conn
+	l.Unlock()
+}
+
+// close closes the current connection, if any.
+func (l *link) close() {
+	l.Lock()
+	defer l.Unlock()
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 2 * time.Minute
+
+	// A connection that stays up this long resets the backoff.
+	stableConnection = time.Minute
+)
+
+// connect dials the server and makes the new connection current.
+func (c *Session) connect() error {
+	c.RLock()
+	cfg := c.cfg
+	if c.gmcpSupports != nil {
+		// keep modules subscribed to at runtime
+		cfg.GMCP.Supports = c.gmcpSupports
+	}
+	if c.width > 0 && c.height > 0 {
+		cfg.Terminal.Width, cfg.Terminal.Height = c.width, c.height
+	}
+	c.RUnlock()
+
+	c.setState(stateConnecting, nil)
+	info.Fprintf(c.output, "[connecting to %s]\n", cfg.Address)
+	conn, err := dial(c.path, cfg)
+	if err != nil {
+		return err
+	}
+	if c.isClosed() {
+		conn.Close()
+		return errors.New("session closed")
+	}
+	conn.HandleGMCPFunc("", c.receiveGMCP)
+	conn.HandleMSDPFunc("", c.receiveMSDP)
+	c.conn.set(conn)
+	if c.stayingDisconnected() {
+		// /disconnect while dialing found no connection to close
+		c.conn.set(nil)
+		conn.Close()
+		return errors.New("disconnected")
+	}
+	c.setState(stateConnected, nil)
+	info.Fprintf(c.output, "[connected to %s]\n", cfg.Address)
+	return nil
+}
+
+// run connects to the server and receives from it until the session is
+// closed, reconnecting with exponential backoff whenever the connection is
+// lost. The backoff grows after a failed dial or a connection that drops
+// before it is stable, so that a server that accepts and then drops
+// connections isn't hammered with logins.
+func (c *Session) run(login bool) error {
+	logch, err := c.startLogWriter()
+	if err != nil {
+		return err
+	}
+
+	backoff := c.minBackoff()
+	for {
+		grow := true
+		if err := c.connect(); err != nil {
+			c.setState(stateDisconnected, err)
+			info.Fprintf(c.output, "[connect: %v]\n", err)
+		} else {
+			conn, _ := c.conn.get()
+			if login {
+				if err := c.Login(); err != nil {
+					info.Fprintf(c.output, "[login: %v]\n", err)
+				}
+			}
+
+			start := time.Now()
+			err = c.receive(conn, logch)
+			conn.Close()
+			c.disconnected(conn)
+			if err == nil {
+				err = errors.New("connection closed")
+			}
+			c.setState(stateDisconnected, err)
+			info.Fprintf(c.output, "[disconnected: %v]\n", err)
+
+			if time.Since(start) >= stableConnection {
+				backoff = c.minBackoff()
+				grow = false
+			}
+		}
+
+		// /disconnect and /reconnect wake the wait, so decide again what to
+		// do after each wake.
+		woken, due, told := false, false, false
+	wait:
+		for {
+			if c.isClosed() {
+				return nil
+			}
+			c.RLock()
+			stay := c.stayDisconnected || c.cfg.Reconnect.Disable && !woken
+			c.RUnlock()
+			switch {
+			case stay:
+				if !told {
+					info.Fprintf(c.output, "[use /reconnect to reconnect]\n")
+					told = true
+				}
+				<-c.reconnect
+				woken = true
+			case woken:
+				backoff = c.minBackoff()
+				break wait
+			case due:
+				break wait
+			default:
+				info.Fprintf(c.output, "[reconnecting in %v]\n", backoff)
+				select {
+				case <-time.After(backoff):
+					if grow {
+						backoff *= 2
+						if max := c.maxBackoff(); backoff > max {
+							backoff = max
+						}
+					}
+					due = true
+				case <-c.reconnect:
+					woken = true
+				}
+			}
+		}
+	}
+}
+
+// wake interrupts the connection loop if it is waiting to reconnect.
+func (c *Session) wake() {
+	select {
+	case c.reconnect <- struct{}{}:
+	default:
+	}
+}
+
+// stayingDisconnected reports whether /disconnect has been used since the
+// last /reconnect.
+func (c *Session) stayingDisconnected() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.stayDisconnected
+}
+
+func (c *Session) isClosed() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.closed
+}
+
+// disconnected clears the session's connection, keeping the state that
+// should survive a reconnect.
+func (c *Session) disconnected(conn *telnet.Conn) {
+	c.Lock()
+	c.gmcpSupports = conn.GMCPSupports()
+	c.Unlock()
+	c.conn.set(nil)
+}
+
+func (c *Session) minBackoff() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cfg.Reconnect.Min > 0 {
+		return c.cfg.Reconnect.Min
+	}
+	return defaultMinBackoff
+}
+
+func (c *Session) maxBackoff() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cfg.Reconnect.Max > 0 {
+		return c.cfg.Reconnect.Max
+	}
+	return defaultMaxBackoff
+}
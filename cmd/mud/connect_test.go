@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen starts a server that passes each connection to handle, returning
+// its address.
+func listen(t *testing.T, handle func(net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testSession returns a session for the server at addr that reconnects
+// after min, doubling up to max, and a channel of the lines it displays.
+func testSession(t *testing.T, addr string, min, max time.Duration) (*Session, <-chan string) {
+	ir, iw := io.Pipe()
+	r, w := io.Pipe()
+	sess := &Session{
+		path:      t.TempDir(),
+		conn:      new(link),
+		input:     pipe{r: ir, w: iw},
+		output:    pipe{r: r, w: w},
+		reconnect: make(chan struct{}, 1),
+	}
+	sess.cfg.Address = addr
+	sess.cfg.Reconnect.Min = min
+	sess.cfg.Reconnect.Max = max
+
+	lines := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	return sess, lines
+}
+
+// expect reads lines until one starts with prefix, failing if none does
+// within a second.
+func expect(t *testing.T, lines <-chan string, prefix string) string {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("output closed waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", prefix)
+		}
+	}
+}
+
+// closeConn is a server that drops each connection at once.
+func closeConn(conn net.Conn) { conn.Close() }
+
+func TestReconnectBackoff(t *testing.T) {
+	addr := listen(t, closeConn)
+	sess, lines := testSession(t, addr, 10*time.Millisecond, time.Second)
+	done := make(chan error)
+	go func() {
+		done <- sess.run(false)
+	}()
+
+	var got []string
+	for len(got) < 4 {
+		got = append(got, expect(t, lines, "[reconnecting in "))
+	}
+	sess.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"[reconnecting in 10ms]",
+		"[reconnecting in 20ms]",
+		"[reconnecting in 40ms]",
+		"[reconnecting in 80ms]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDisconnectWhileWaiting(t *testing.T) {
+	accepted := make(chan bool, 100)
+	addr := listen(t, func(conn net.Conn) {
+		accepted <- true
+		conn.Close()
+	})
+	sess, lines := testSession(t, addr, 50*time.Millisecond, time.Second)
+	done := make(chan error)
+	go func() {
+		done <- sess.run(false)
+	}()
+	defer func() {
+		sess.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	expect(t, lines, "[reconnecting in ")
+	disconnect(sess)
+	expect(t, lines, "[use /reconnect to reconnect]")
+	<-accepted
+
+	// the backoff has passed, but the session stays disconnected
+	select {
+	case <-accepted:
+		t.Fatal("reconnected after /disconnect")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	reconnect(sess)
+	expect(t, lines, "[connecting to ")
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("no connection after /reconnect")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jnjackins/mud"
+	"github.com/jnjackins/mud/telnet"
+)
+
+// dial connects to the mud server configured for the session at path. The
+// address may be given as telnet://host:port, or as telnets://host:port to
+// connect with TLS.
+func dial(path string, cfg mud.Config) (*telnet.Conn, error) {
+	tc, err := telnetConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := cfg.Address
+	useTLS := cfg.TLS
+	if strings.HasPrefix(addr, "telnets://") {
+		addr = strings.TrimPrefix(addr, "telnets://")
+		useTLS = true
+	} else {
+		addr = strings.TrimPrefix(addr, "telnet://")
+	}
+
+	if useTLS {
+		tc.TLS, err = tlsConfig(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return telnet.DialConfig("tcp", addr, tc)
+}
+
+// tlsConfig returns the TLS settings for the session at path. A relative
+// tls_ca file is relative to the session directory.
+func tlsConfig(path string, cfg mud.Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.TLSInsecure,
+	}
+	if cfg.TLSCA != "" {
+		ca := cfg.TLSCA
+		if !filepath.IsAbs(ca) {
+			ca = filepath.Join(path, ca)
+		}
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", ca)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jnjackins/mud"
+)
+
+// dumpTimeout is how long a dump waits for the prompt that ends its output.
+const dumpTimeout = 10 * time.Second
+
+// A dump captures the output of a command, to be written to a file.
+type dump struct {
+	name  string
+	cfg   *mud.DumpConfig
+	lines []string
+
+	// started is set once output other than a prompt has arrived, so that a
+	// prompt already on its way when the command was sent doesn't end the
+	// dump.
+	started bool
+}
+
+// startDump sends the command of the named dump, and captures its output
+// until the next prompt.
+func (c *Session) startDump(name string) error {
+	c.Lock()
+	cfg, ok := c.cfg.Dump[name]
+	if !ok || cfg == nil {
+		c.Unlock()
+		return fmt.Errorf("no dump %q", name)
+	}
+	if c.dumping != nil {
+		c.Unlock()
+		return fmt.Errorf("dump %q in progress", c.dumping.name)
+	}
+	d := &dump{name: name, cfg: cfg}
+	c.dumping = d
+	cmds := c.expand(cfg.Cmd)
+	c.Unlock()
+
+	time.AfterFunc(dumpTimeout, func() {
+		c.Lock()
+		defer c.Unlock()
+		if c.dumping == d {
+			c.dumping = nil
+			info.Fprintf(c.output, "[dump %s: no prompt after %v]\n", name, dumpTimeout)
+		}
+	})
+
+	for _, sub := range cmds {
+		if _, err := fmt.Fprintln(c.conn, sub); err != nil {
+			c.Lock()
+			c.dumping = nil
+			c.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// captureDump adds line to the dump in progress, if any, and writes the dump
+// when the prompt arrives.
+func (c *Session) captureDump(line []byte, prompt bool) {
+	c.Lock()
+	d := c.dumping
+	if d == nil {
+		c.Unlock()
+		return
+	}
+	if !prompt {
+		d.started = true
+		d.lines = append(d.lines, matchDump(d.cfg.Match, line)...)
+		c.Unlock()
+		return
+	}
+	if !d.started {
+		c.Unlock()
+		return
+	}
+	c.dumping = nil
+	c.Unlock()
+
+	dest := d.cfg.File(d.name)
+	path := filepath.Join(c.path, dest)
+	if err := writeFileAtomic(path, d.lines); err != nil {
+		info.Fprintf(c.output, "[dump %s: %v]\n", d.name, err)
+		return
+	}
+	info.Fprintf(c.output, "[dump %s: %d lines to %s]\n", d.name, len(d.lines), dest)
+}
+
+// matchDump returns the expansion of each pattern in match that matches
+// line, in the order of the patterns.
+func matchDump(match map[mud.Pattern]string, line []byte) []string {
+	var lines []string
+	for _, pattern := range sortedPatterns(match) {
+		if pattern.Match(line) {
+			lines = append(lines, pattern.Expand(line, match[pattern]))
+		}
+	}
+	return lines
+}
+
+// writeFileAtomic writes lines to a temporary file and renames it to path,
+// so that readers never see a partial file.
+func writeFileAtomic(path string, lines []string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	var data string
+	if len(lines) > 0 {
+		data = strings.Join(lines, "\n") + "\n"
+	}
+	if _, err := f.WriteString(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// sortedPatterns returns the patterns in match, sorted.
+func sortedPatterns(match map[mud.Pattern]string) []mud.Pattern {
+	patterns := make([]mud.Pattern, 0, len(match))
+	for pattern := range match {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return patterns[i] < patterns[j] })
+	return patterns
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jnjackins/mud"
+	"github.com/jnjackins/mud/internal/interpolate"
+	"github.com/jnjackins/mud/telnet"
+)
+
+// receiveGMCP keeps the latest payload of each GMCP package, so that it can
+// be expanded as $gmcp.Package.field, and runs any GMCP triggers.
+func (c *Session) receiveGMCP(msg *telnet.GMCPMessage) {
+	c.Lock()
+	c.gmcp[strings.ToLower(msg.Package)] = msg.Data
+
+	var cmds []string
+	if !c.triggersDisabled {
+		for _, t := range c.cfg.GMCPTriggers {
+			if c.gmcpTriggerFires(t, msg) {
+				cmds = append(cmds, c.gmcpTriggerCmds(t, msg)...)
+			}
+		}
+
+		// ad-hoc one-time triggers
+		remaining := c.oneTimeGMCP[:0]
+		for _, t := range c.oneTimeGMCP {
+			if c.gmcpTriggerFires(t, msg) {
+				cmds = append(cmds, c.gmcpTriggerCmds(t, msg)...)
+			} else {
+				remaining = append(remaining, t)
+			}
+		}
+		c.oneTimeGMCP = remaining
+	}
+	c.Unlock()
+
+	for _, sub := range cmds {
+		if !c.command(sub) {
+			fmt.Fprintln(c.conn, sub)
+		}
+	}
+}
+
+// gmcpTriggerFires reports whether t fires for msg. The caller must hold the
+// lock.
+func (c *Session) gmcpTriggerFires(t mud.GMCPTrigger, msg *telnet.GMCPMessage) bool {
+	if !telnet.MatchGMCP(t.Package, msg.Package) {
+		return false
+	}
+	if t.If == nil {
+		return true
+	}
+
+	v, ok := walkJSON(msg.Data, strings.Split(t.If.Field, "."))
+	if !ok {
+		return false
+	}
+	e := c.env()
+	e.payload = msg.Data
+	value, err := interpolate.Interpolate(e, nil, t.If.Value)
+	if err != nil {
+		info.Fprintf(c.output, "[ERROR: %v]\n", err)
+		return false
+	}
+	return t.If.Holds(formatJSON(v), value)
+}
+
+// gmcpTriggerCmds expands the command of a GMCP trigger, with the fields of
+// the message payload available as $msg.field. The caller must hold the
+// lock.
+func (c *Session) gmcpTriggerCmds(t mud.GMCPTrigger, msg *telnet.GMCPMessage) []string {
+	info.Fprintf(c.output, "[trigger: %s: %s]\n", msg.Package, t.Do)
+
+	e := c.env()
+	e.payload = msg.Data
+	return c.expandEnv(t.Do, e)
+}
+
+// lookupGMCP resolves a path like "Char.Vitals.hp" against the latest GMCP
+// payloads. The longest matching package name is used, and the rest of the
+// path selects fields of objects or indexes of arrays in its payload.
+func lookupGMCP(payloads map[string]interface{}, path string) (string, bool) {
+	parts := strings.Split(path, ".")
+	for i := len(parts); i > 0; i-- {
+		payload, ok := payloads[strings.ToLower(strings.Join(parts[:i], "."))]
+		if !ok {
+			continue
+		}
+		if v, ok := walkJSON(payload, parts[i:]); ok {
+			return formatJSON(v), true
+		}
+	}
+	return "", false
+}
+
+func walkJSON(v interface{}, path []string) (interface{}, bool) {
+	for _, name := range path {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			field, ok := x[name]
+			if !ok {
+				for k := range x {
+					if strings.EqualFold(k, name) {
+						field, ok = x[k], true
+						break
+					}
+				}
+			}
+			if !ok {
+				return nil, false
+			}
+			v = field
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// formatJSON formats a decoded JSON value for interpolation. Objects and
+// arrays are formatted as JSON.
+func formatJSON(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		buf, _ := json.Marshal(x)
+		return string(buf)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jnjackins/mud/internal/interpolate"
+)
+
+func TestGMCPVars(t *testing.T) {
+	payloads := make(map[string]interface{})
+	for pkg, data := range map[string]string{
+		"char.vitals": `{"hp": 95, "maxhp": 120.5, "fighting": false}`,
+		"room.info":   `{"num": 1234, "name": "A dark alley", "exits": {"n": 1235}, "details": ["shop", "bank"]}`,
+	} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			t.Fatal(err)
+		}
+		payloads[pkg] = v
+	}
+	e := env{vars: mapvars{"tank": "mikal"}, gmcp: payloads}
+
+	tests := map[string]struct {
+		template string
+		result   string
+	}{
+		"number":          {template: "$gmcp.Char.Vitals.hp", result: "95"},
+		"float":           {template: "$gmcp.Char.Vitals.maxhp", result: "120.5"},
+		"bool":            {template: "$gmcp.Char.Vitals.fighting", result: "false"},
+		"braces":          {template: "${gmcp.Room.Info.num}", result: "1234"},
+		"case":            {template: "$gmcp.room.info.NAME", result: "A dark alley"},
+		"nested":          {template: "$gmcp.Room.Info.exits.n", result: "1235"},
+		"index":           {template: "$gmcp.Room.Info.details.1", result: "bank"},
+		"object":          {template: "$gmcp.Room.Info.exits", result: `{"n":1235}`},
+		"trailing dot":    {template: "hp is $gmcp.Char.Vitals.hp.", result: "hp is 95."},
+		"missing field":   {template: "$gmcp.Char.Vitals.mp", result: "$gmcp.Char.Vitals.mp"},
+		"missing package": {template: "$gmcp.Char.Status.level", result: "$gmcp.Char.Status.level"},
+		"plain var":       {template: "watch $tank", result: "watch mikal"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := interpolate.Interpolate(e, nil, test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.result {
+				t.Errorf("got %q, want %q", got, test.result)
+			}
+		})
+	}
+}
+
+func TestGMCPTriggerVars(t *testing.T) {
+	var payload interface{}
+	if err := json.Unmarshal([]byte(`{"tank": "bob", "name": "A dark alley", "exits": {"n": 1235}}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	e := env{vars: mapvars{"tank": "mikal"}, payload: payload}
+
+	tests := map[string]struct {
+		template string
+		result   string
+	}{
+		"field":         {template: "say $msg.name", result: "say A dark alley"},
+		"nested":        {template: "go $msg.exits.n", result: "go 1235"},
+		"var":           {template: "watch $tank", result: "watch mikal"},
+		"same name":     {template: "$tank $msg.tank", result: "mikal bob"},
+		"missing field": {template: "$msg.mp", result: "$msg.mp"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := interpolate.Interpolate(e, nil, test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.result {
+				t.Errorf("got %q, want %q", got, test.result)
+			}
+		})
+	}
+}
+
+func TestGMCPUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"subscribe"}, {"unsubscribe"}} {
+		sess, out := newTestSession(t, "")
+		gmcp(sess, args...)
+		if want := "gmcp: usage: /gmcp subscribe|unsubscribe <module> ...\n"; out.String() != want {
+			t.Errorf("/gmcp %v: got %q, want %q", args, out.String(), want)
+		}
+	}
+}
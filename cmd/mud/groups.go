@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jnjackins/mud"
+)
+
+// groupEnabled reports whether the triggers, aliases, timers and highlights
+// in group are enabled. Those without a group always are. The caller must
+// hold the lock.
+func (c *Session) groupEnabled(group string) bool {
+	return group == "" || !c.disabledGroups[group]
+}
+
+// groupSize counts the things in a group.
+type groupSize struct {
+	triggers, aliases, timers, highlights int
+}
+
+func (g groupSize) String() string {
+	var parts []string
+	for _, n := range []struct {
+		count int
+		what  string
+	}{
+		{g.triggers, "trigger"},
+		{g.aliases, "alias"},
+		{g.timers, "timer"},
+		{g.highlights, "highlight"},
+	} {
+		switch {
+		case n.count == 1:
+			parts = append(parts, "1 "+n.what)
+		case n.count > 1 && n.what == "alias":
+			parts = append(parts, fmt.Sprintf("%d aliases", n.count))
+		case n.count > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n.count, n.what))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// groups returns the size of each group in cfg.
+func groups(cfg mud.Config) map[string]*groupSize {
+	m := make(map[string]*groupSize)
+	get := func(name string) *groupSize {
+		if m[name] == nil {
+			m[name] = new(groupSize)
+		}
+		return m[name]
+	}
+	for _, triggers := range []mud.Triggers{cfg.Triggers, cfg.PromptTriggers} {
+		for _, t := range triggers {
+			if t.Group != "" {
+				get(t.Group).triggers++
+			}
+		}
+	}
+	for _, a := range cfg.Aliases {
+		if a.Group != "" {
+			get(a.Group).aliases++
+		}
+	}
+	for _, t := range cfg.Timers {
+		if t.Group != "" {
+			get(t.Group).timers++
+		}
+	}
+	for _, h := range cfg.Highlight {
+		if h.Group != "" {
+			get(h.Group).highlights++
+		}
+	}
+	return m
+}
+
+// setGroup enables or disables a configured group.
+func (c *Session) setGroup(group string, enabled bool) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := groups(c.cfg)[group]; !ok {
+		return fmt.Errorf("unknown group %q", group)
+	}
+	if enabled {
+		delete(c.disabledGroups, group)
+	} else {
+		c.disabledGroups[group] = true
+	}
+	return nil
+}
+
+// listGroups writes each configured group, whether it's enabled, and what's
+// in it.
+func (c *Session) listGroups() {
+	c.RLock()
+	defer c.RUnlock()
+
+	sizes := groups(c.cfg)
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state := "on"
+		if !c.groupEnabled(name) {
+			state = "off"
+		}
+		fmt.Fprintf(c.output, "%s\t%s\t%v\n", name, state, sizes[name])
+	}
+}
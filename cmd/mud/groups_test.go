@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnjackins/mud"
+)
+
+func TestGroups(t *testing.T) {
+	config := `
+triggers:
+  - match: attacks you
+    do: flee
+    group: combat
+  - match: attacks you
+    do: say ouch
+highlight:
+  - match: goblin
+    color: red
+    group: combat
+aliases:
+  k:
+    do: kill $1
+    group: combat
+timers:
+  - every: 10ms
+    do: parry
+    group: combat
+`
+	tests := map[string]struct {
+		cmds      []string
+		out       string
+		alias     []string
+		highlight bool
+		timer     bool
+	}{
+		"enabled": {
+			out:       "A goblin attacks you.\n[trigger: flee]\n> flee\n[trigger: say ouch]\n> say ouch\n",
+			alias:     []string{"kill goblin"},
+			highlight: true,
+			timer:     true,
+		},
+		"disabled": {
+			cmds:  []string{"/disable combat"},
+			out:   "A goblin attacks you.\n[trigger: say ouch]\n> say ouch\n",
+			alias: []string{"k goblin"},
+		},
+		"enabled again": {
+			cmds:      []string{"/disable combat", "/enable combat"},
+			out:       "A goblin attacks you.\n[trigger: flee]\n> flee\n[trigger: say ouch]\n> say ouch\n",
+			alias:     []string{"kill goblin"},
+			highlight: true,
+			timer:     true,
+		},
+		"unknown group": {
+			cmds:      []string{"/disable fighting"},
+			out:       "disable: unknown group \"fighting\"\nA goblin attacks you.\n[trigger: flee]\n> flee\n[trigger: say ouch]\n> say ouch\n",
+			alias:     []string{"kill goblin"},
+			highlight: true,
+			timer:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sess, out := newTestSession(t, config)
+			out.Reset()
+			for _, cmd := range test.cmds {
+				sess.command(cmd)
+			}
+
+			// timers, ignoring any ticks before the commands ran
+			got := out.String()
+			out.Reset()
+			time.Sleep(50 * time.Millisecond)
+			if timer := strings.Contains(out.String(), "> parry\n"); timer != test.timer {
+				t.Errorf("got timer %v, want %v", timer, test.timer)
+			}
+			sess.cancelTimers()
+			time.Sleep(20 * time.Millisecond)
+
+			// triggers
+			out.Reset()
+			sess.handleLine([]byte("A goblin attacks you."), true, func(*mud.Line) {})
+			got += out.String()
+			if diff := cmp.Diff(test.out, got); diff != "" {
+				t.Errorf("output mismatch: %v", diff)
+			}
+
+			sess.RLock()
+			alias := sess.expand("k goblin")
+			_, highlight := sess.highlight([]byte("A goblin attacks you."))
+			sess.RUnlock()
+			if diff := cmp.Diff(test.alias, alias); diff != "" {
+				t.Errorf("alias mismatch: %v", diff)
+			}
+			if highlight != test.highlight {
+				t.Errorf("got highlight %v, want %v", highlight, test.highlight)
+			}
+		})
+	}
+}
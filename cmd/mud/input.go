@@ -13,11 +13,21 @@ func (c *client) input() {
 	l := liner.NewLiner()
 	defer l.Close()
 
-	l.SetWordCompleter(c.main.complete)
 	l.SetTabCompletionStyle(liner.TabCircular)
 
 	for {
-		s, err := l.Prompt(c.main.cfg.Login.Name + "> ")
+		main := c.mainSession()
+		if main == nil {
+			log.Println("no sessions left")
+			return
+		}
+		l.SetWordCompleter(main.complete)
+
+		main.RLock()
+		name := main.cfg.Login.Name
+		main.RUnlock()
+
+		s, err := l.Prompt(name + "> ")
 		if err != nil {
 			if err == io.EOF {
 				return
@@ -29,13 +39,16 @@ func (c *client) input() {
 		// comma-separated prefixes, and send commands to all sessions specified
 		// by the prefixes. If no sessions are specified, send to main.
 		// example command: `a,b look; b jump`
-		for n, cmd := range c.main.expand(s) {
+		main.RLock()
+		cmds := main.expand(s)
+		main.RUnlock()
+		for n, cmd := range cmds {
 			cmd = strings.TrimSpace(cmd)
 
-			if sess, ok := c.sessions[cmd]; ok {
+			if sess, ok := c.session(cmd); ok {
 				// only prefix was sent: change main to given session
-				c.main = sess
-				l.SetWordCompleter(c.main.complete)
+				c.setMain(sess)
+				main = sess
 				continue
 			}
 
@@ -43,13 +56,13 @@ func (c *client) input() {
 			fields := strings.Fields(cmd)
 			if len(fields) > 0 {
 				for _, prefix := range strings.Split(fields[0], ",") {
-					if sess, ok := c.sessions[prefix]; ok {
+					if sess, ok := c.session(prefix); ok {
 						inputs = append(inputs, sess.input)
 					}
 				}
 			}
 			if len(inputs) == 0 {
-				inputs = append(inputs, c.main.input)
+				inputs = append(inputs, main.input)
 			} else {
 				// remove prefixes before sending cmd
 				cmd = strings.Join(fields[1:], " ")
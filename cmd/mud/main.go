@@ -7,16 +7,21 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/jnjackins/mud"
-	"github.com/jnjackins/mud/telnet"
 )
 
 type client struct {
+	sync.Mutex
 	sessions map[string]*Session
+	status   map[*Session]*sessionStatus
 	main     *Session
+
+	// serving and login are the command line flags, used for sessions
+	// started at runtime with /connect.
+	serving, login bool
 }
 
 func main() {
@@ -27,6 +32,8 @@ func main() {
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s prefix:path ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s check path ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s test [-golden file [-update]] path transcript\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -36,31 +43,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	if flag.Arg(0) == "check" {
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		if !checkConfigs(os.Stdout, flag.Args()[1:]) {
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "test" {
+		os.Exit(testConfig(flag.Args()[1:]))
+	}
+
 	c := &client{
 		sessions: make(map[string]*Session),
+		status:   make(map[*Session]*sessionStatus),
+		serving:  *serve,
+		login:    *login,
 	}
-	for _, arg := range flag.Args() {
-		parts := strings.Split(arg, ":")
-		if len(parts) != 2 {
-			log.Fatalf("bad session %q", arg)
-		}
-		prefix := parts[0]
-		if prefix == "" {
-			log.Fatalf("empty session prefix")
-		}
-		path := parts[1]
+	defer c.closeAll()
 
-		sess, err := c.startSession(prefix, path, *serve, *login)
+	// a session that can't be started is reported, but doesn't stop the others
+	for _, arg := range flag.Args() {
+		prefix, path, err := parseSession(arg)
 		if err != nil {
-			log.Fatal(err)
+			log.Print(err)
+			continue
+		}
+		if _, err := c.startSession(prefix, path); err != nil {
+			log.Printf("%s: %v", prefix, err)
 		}
-		defer sess.Close()
+	}
+	if c.mainSession() == nil {
+		log.Fatal("no sessions started")
 	}
 
 	c.input()
 }
 
-func (c *client) startSession(prefix, path string, serve, login bool) (*Session, error) {
+func (c *client) startSession(prefix, path string) (*Session, error) {
+	if _, ok := c.session(prefix); ok {
+		return nil, fmt.Errorf("session %q already exists", prefix)
+	}
+
 	cfg, err := mud.UnmarshalConfig(path + "/config.yaml")
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
@@ -71,40 +98,48 @@ func (c *client) startSession(prefix, path string, serve, login bool) (*Session,
 		return nil, err
 	}
 
-	log.Printf("%s: connecting", path)
-	conn, err := telnet.Dial("tcp", cfg.Address)
-	if err != nil {
-		return nil, err
-	}
-
 	sess := &Session{
 		prefix: prefix,
 		path:   path,
+		client: c,
 
-		conn:   conn,
-		input:  input,
-		output: output,
+		conn:      new(link),
+		input:     input,
+		output:    output,
+		reconnect: make(chan struct{}, 1),
 
-		vars:            make(mapvars),
-		lists:           make(map[string][]string),
-		oneTimeTriggers: make(map[mud.Pattern]string),
+		vars:           make(mapvars),
+		gmcp:           make(map[string]interface{}),
+		msdp:           make(map[string]interface{}),
+		lists:          make(map[string][]string),
+		disabledGroups: make(map[string]bool),
+		abilities:      make(map[string]bool),
+		whenReady:      make(map[string][]string),
 	}
 	sess.SetConfig(cfg)
-
-	if serve {
-		go func() {
-			if err := c.serve(sess, login); err != nil {
-				log.Fatalf("serve: %v", err)
-			}
-		}()
-	}
-
 	sess.startCompleter()
 
+	c.Lock()
+	if _, ok := c.sessions[prefix]; ok {
+		c.Unlock()
+		sess.Close()
+		return nil, fmt.Errorf("session %q already exists", prefix)
+	}
 	c.sessions[prefix] = sess
 	if c.main == nil {
 		c.main = sess
 	}
+	c.Unlock()
+
+	if c.serving {
+		go func() {
+			if err := c.serve(sess); err != nil && !sess.isClosed() {
+				info.Fprintf(sess.output, "[session failed: %v]\n", err)
+				c.setState(sess, stateClosed, err)
+				sess.Close()
+			}
+		}()
+	}
 
 	return sess, nil
 }
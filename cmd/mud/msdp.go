@@ -0,0 +1,26 @@
+package main
+
+import "strings"
+
+// receiveMSDP keeps the latest value of each MSDP variable, so that it can
+// be expanded as $msdp.NAME.
+func (c *Session) receiveMSDP(name string, value interface{}) {
+	c.Lock()
+	c.msdp[strings.ToUpper(name)] = value
+	c.Unlock()
+}
+
+// lookupMSDP resolves a path like "HEALTH" or "ROOM.EXITS.n" against the
+// latest MSDP values. Tables and arrays are walked in the same way as GMCP
+// payloads.
+func lookupMSDP(values map[string]interface{}, path string) (string, bool) {
+	parts := strings.Split(path, ".")
+	value, ok := values[strings.ToUpper(parts[0])]
+	if !ok {
+		return "", false
+	}
+	if v, ok := walkJSON(value, parts[1:]); ok {
+		return formatJSON(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jnjackins/mud"
+)
+
+// recentLines keeps the last lines of output, other than prompts, for
+// multi-line triggers.
+type recentLines struct {
+	sync.Mutex
+	lines []string
+	n     int // lines added so far
+
+	// used records, for each multi-line pattern, n at the time it last
+	// matched, so that lines are only part of one match of each pattern.
+	used map[mud.Pattern]int
+}
+
+func (r *recentLines) add(line []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.lines = append(r.lines, string(line))
+	if len(r.lines) > mud.MaxWithin {
+		r.lines = r.lines[len(r.lines)-mud.MaxWithin:]
+	}
+	r.n++
+}
+
+// window returns up to size of the most recent lines that haven't been part
+// of a match of seq, joined by newlines.
+func (r *recentLines) window(seq mud.Pattern, size int) []byte {
+	r.Lock()
+	defer r.Unlock()
+
+	if unused := r.n - r.used[seq]; size > unused {
+		size = unused
+	}
+	if size > len(r.lines) {
+		size = len(r.lines)
+	}
+	return []byte(strings.Join(r.lines[len(r.lines)-size:], "\n"))
+}
+
+// use marks the lines so far as part of a match of seq.
+func (r *recentLines) use(seq mud.Pattern) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.used == nil {
+		r.used = make(map[mud.Pattern]int)
+	}
+	r.used[seq] = r.n
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jnjackins/mud"
+)
+
+// testConfig runs mud test: it replays a transcript through a session's
+// configuration, and prints the report or compares it with a golden file.
+// It returns the exit status.
+func testConfig(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	golden := fs.String("golden", "", "Compare the report with this file")
+	update := fs.Bool("update", false, "Write the report to the golden file instead of comparing")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s test [-golden file [-update]] path transcript\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 || (*update && *golden == "") {
+		fs.Usage()
+		return 2
+	}
+
+	var report bytes.Buffer
+	if err := replay(&report, fs.Arg(0), fs.Arg(1)); err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	switch {
+	case *golden == "":
+		os.Stdout.Write(report.Bytes())
+	case *update:
+		if err := ioutil.WriteFile(*golden, report.Bytes(), 0644); err != nil {
+			log.Print(err)
+			return 1
+		}
+	default:
+		want, err := ioutil.ReadFile(*golden)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if !bytes.Equal(report.Bytes(), want) {
+			diffLines(os.Stdout, *golden, string(want), report.String())
+			return 1
+		}
+	}
+	return 0
+}
+
+// replay runs each line of the transcript through the configuration of the
+// session at path, as if it had come from the server, and writes a report to
+// w. Each line of the report is something the session would have displayed,
+// a command it would have sent ("> cmd") or a line it would have logged
+// ("log name: line"), preceded by the number of the transcript line that
+// caused it. Timers don't run, and nothing is written to the session
+// directory. Shell commands, /dump and /wait are reported as if they were
+// sent instead of being run.
+func replay(w io.Writer, path, transcript string) error {
+	cfg, err := mud.UnmarshalConfig(filepath.Join(path, "config.yaml"))
+	if err != nil {
+		return err
+	}
+	cfg.Timers = nil
+	cfg.AbilityLog = ""
+
+	data, err := ioutil.ReadFile(transcript)
+	if err != nil {
+		return err
+	}
+
+	// highlights would otherwise depend on the terminal
+	color.NoColor = true
+
+	var out bytes.Buffer
+	sess := &Session{
+		prefix: "test",
+		path:   path,
+		conn:   &link{record: prefixWriter{"> ", &out}},
+		output: pipe{w: nopCloser{&out}},
+
+		replaying: true,
+
+		vars:           make(mapvars),
+		gmcp:           make(map[string]interface{}),
+		msdp:           make(map[string]interface{}),
+		lists:          make(map[string][]string),
+		disabledGroups: make(map[string]bool),
+		abilities:      make(map[string]bool),
+		whenReady:      make(map[string][]string),
+	}
+	sess.SetConfig(cfg)
+
+	logs := make(map[string]io.Writer)
+	for name, v := range sess.cfg.Log {
+		if err := checkLogColor(v.Color); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		logs[name] = prefixWriter{"log " + name + ": ", &out}
+
+		// timestamps would make every report different
+		v.Timestamp = false
+		sess.cfg.Log[name] = v
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range lines {
+		out.Reset()
+		sess.handleLine(dropCR([]byte(line)), true, func(l *mud.Line) {
+			sess.writeLogs(logs, l)
+		})
+		for _, s := range strings.SplitAfter(out.String(), "\n") {
+			if s == "" {
+				continue
+			}
+			plain := mud.NewLine([]byte(strings.TrimSuffix(s, "\n"))).Plain
+			fmt.Fprintf(w, "%d: %s\n", i+1, plain)
+		}
+	}
+	return nil
+}
+
+// A prefixWriter writes each line with a prefix.
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+}
+
+func (p prefixWriter) Write(b []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if _, err := io.WriteString(p.w, p.prefix+string(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// diffLines writes the lines that differ between want, the contents of the
+// named file, and got.
+func diffLines(w io.Writer, name, want, got string) {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := "(none)", "(none)"
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			fmt.Fprintf(w, "%s:%d:\n\twant: %s\n\tgot:  %s\n", name, i+1, x, y)
+		}
+	}
+}
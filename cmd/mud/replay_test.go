@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReplay(t *testing.T) {
+	config := `
+prompt: '^<(?P<hp>[0-9]+)hp>$'
+triggers:
+  - match: There were (\d+) coins.
+    do: split $1
+  - match: literal:You are hungry.
+    do: eat bread; drink water
+    stop: true
+  - match: hungry
+    do: never
+prompt_triggers:
+  '<[0-9]hp>': flee
+gag:
+  - 'aims a magic missile'
+replace:
+  '^(\w+) leaves (\w+)\.$':
+    with: '$1 -> $2'
+log:
+  chat:
+    timestamp: true
+    match:
+      "tells you '(.*)'": $1
+`
+	transcript := "You are hungry.\r\n" +
+		"There were 12 coins.\n" +
+		"Fido aims a magic missile at a goblin.\n" +
+		"Bob leaves north.\n" +
+		"Bob tells you '\x1b[33mhi\x1b[0m'\n" +
+		"<5hp>\n"
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "transcript.txt")
+	if err := ioutil.WriteFile(path, []byte(transcript), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := replay(&buf, dir, path); err != nil {
+		t.Fatal(err)
+	}
+	want := `1: You are hungry.
+1: [trigger: eat bread; drink water]
+1: > eat bread
+1: > drink water
+2: There were 12 coins.
+2: [trigger: split 12]
+2: > split 12
+4: Bob -> north
+5: log chat: hi
+5: Bob tells you 'hi'
+6: <5hp>
+6: [trigger: flee]
+6: > flee
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("report mismatch: %v", diff)
+	}
+}
+
+func TestReplaySideEffects(t *testing.T) {
+	dir := t.TempDir()
+	touched := filepath.Join(dir, "touched")
+	config := "triggers:\n" +
+		"  You are hungry.: '!touch " + touched + "'\n" +
+		"  You are tired.: /wait 1h; sleep\n" +
+		"  You see a map.: /dump map\n"
+	transcript := "You are hungry.\nYou are tired.\nYou see a map.\n"
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "transcript.txt")
+	if err := ioutil.WriteFile(path, []byte(transcript), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := replay(&buf, dir, path); err != nil {
+		t.Fatal(err)
+	}
+	want := `1: You are hungry.
+1: [trigger: !touch ` + touched + `]
+1: > !touch ` + touched + `
+2: You are tired.
+2: [trigger: /wait 1h; sleep]
+2: > /wait 1h
+2: > sleep
+3: You see a map.
+3: [trigger: /dump map]
+3: > /dump map
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("report mismatch: %v", diff)
+	}
+	if _, err := os.Stat(touched); !os.IsNotExist(err) {
+		t.Errorf("shell command ran: stat %s: %v", touched, err)
+	}
+}
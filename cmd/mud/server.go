@@ -8,7 +8,7 @@ import (
 	"github.com/jnjackins/mud"
 )
 
-func (c *client) serve(sess *Session, login bool) error {
+func (c *client) serve(sess *Session) error {
 	log := log.New(os.Stderr, sess.path+": ", log.LstdFlags)
 
 	// Monitor config file for changes
@@ -20,15 +20,21 @@ func (c *client) serve(sess *Session, login bool) error {
 	mtime := fi.ModTime()
 	go func() {
 		for range time.Tick(5 * time.Second) {
+			if sess.isClosed() {
+				return
+			}
 			fi, err := os.Stat(cfgPath)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
 			if fi.ModTime().After(mtime) {
+				mtime = fi.ModTime()
 				cfg, err := mud.UnmarshalConfig(cfgPath)
 				if err != nil {
-					log.Println(err)
+					// keep the previous configuration
+					log.Printf("configuration not updated:\n%v", err)
+					info.Fprintf(sess.output, "[configuration not updated: %v]\n", err)
 					continue
 				}
 
@@ -36,10 +42,9 @@ func (c *client) serve(sess *Session, login bool) error {
 				sess.SetConfig(cfg)
 
 				log.Println("configuration updated")
-				mtime = fi.ModTime()
 			}
 		}
 	}()
 
-	return sess.Start(login)
+	return sess.Start(c.login)
 }
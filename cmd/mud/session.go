@@ -7,11 +7,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"html"
 	"io"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/fvbock/trie"
 	"github.com/jnjackins/mud"
 	"github.com/jnjackins/mud/internal/interpolate"
+	"github.com/jnjackins/mud/telnet"
 
 	"github.com/fatih/color"
 )
@@ -57,22 +59,89 @@ func (m mapvars) Get(key string) (string, bool) {
 	return v, true
 }
 
+// env is the environment that $variables are expanded from: the session's
+// vars, plus out-of-band data from the server under the "gmcp." and "msdp."
+// prefixes, abilities under "ability." and the fields of the GMCP message
+// that fired a trigger under "msg.".
+type env struct {
+	vars mapvars
+	gmcp map[string]interface{}
+	msdp map[string]interface{}
+
+	// abilities maps each configured ability to whether it is ready.
+	abilities map[string]bool
+
+	// payload is the GMCP message that fired a trigger, if any.
+	payload interface{}
+}
+
+// Namespaces returns the prefixes of the dotted $variables in e.
+func (e env) Namespaces() []string {
+	return []string{"gmcp", "msdp", "ability", "msg"}
+}
+
+func (e env) Get(key string) (string, bool) {
+	if e.payload != nil && strings.HasPrefix(key, "msg.") {
+		if v, ok := walkJSON(e.payload, strings.Split(strings.TrimPrefix(key, "msg."), ".")); ok {
+			return formatJSON(v), true
+		}
+	}
+	if strings.HasPrefix(key, "gmcp.") {
+		if v, ok := lookupGMCP(e.gmcp, strings.TrimPrefix(key, "gmcp.")); ok {
+			return v, true
+		}
+	}
+	if strings.HasPrefix(key, "ability.") {
+		if v, ok := lookupAbility(e.abilities, strings.TrimPrefix(key, "ability.")); ok {
+			return v, true
+		}
+	}
+	if strings.HasPrefix(key, "msdp.") {
+		if v, ok := lookupMSDP(e.msdp, strings.TrimPrefix(key, "msdp.")); ok {
+			return v, true
+		}
+	}
+	return e.vars.Get(key)
+}
+
 type Session struct {
 	prefix string
 	path   string
+	client *client
 
-	conn   net.Conn
+	conn   *link
 	input  pipe
 	output pipe
 
+	// reconnect wakes the connection loop to reconnect immediately.
+	reconnect chan struct{}
+
+	// replaying is set by mud test, which reports commands that would
+	// reach outside the session instead of running them.
+	replaying bool
+
 	sync.RWMutex
 	cfg              mud.Config
 	vars             mapvars
+	gmcp             map[string]interface{}
+	msdp             map[string]interface{}
 	lists            map[string][]string
 	history          []string
 	triggersDisabled bool
+	disabledGroups   map[string]bool
 	cancelTimers     context.CancelFunc
-	oneTimeTriggers  map[mud.Pattern]string
+	oneTimeTriggers  mud.Triggers
+	oneTimeGMCP      []mud.GMCPTrigger
+	recent           recentLines
+	abilities        map[string]bool
+	whenReady        map[string][]string
+	dumping          *dump
+
+	// connection state, kept across reconnects
+	closed           bool
+	stayDisconnected bool
+	gmcpSupports     []string
+	width, height    int
 
 	// tab completion
 	words       *trie.Trie
@@ -80,6 +149,15 @@ type Session struct {
 }
 
 func (s *Session) Close() error {
+	s.Lock()
+	s.closed = true
+	if s.cancelTimers != nil {
+		s.cancelTimers()
+	}
+	s.Unlock()
+	s.conn.close()
+	s.wake()
+
 	s.input.Close()
 	s.output.Close()
 	return nil
@@ -89,21 +167,13 @@ func (s *Session) Start(login bool) error {
 	errors := make(chan error)
 
 	go func() {
-		errors <- s.receive()
+		errors <- s.run(login)
 	}()
 
 	go func() {
 		errors <- s.send()
 	}()
 
-	if login {
-		go func() {
-			if err := s.Login(); err != nil {
-				errors <- err
-			}
-		}()
-	}
-
 	return <-errors
 }
 
@@ -114,7 +184,7 @@ func dropCR(data []byte) []byte {
 	return data
 }
 
-func (c *Session) receive() error {
+func (c *Session) receive(conn *telnet.Conn, logch chan<- *mud.Line) error {
 	// mud doesn't send a newline after the prompt; we rigged the telnet reader
 	// to send \x04 (EOT) instead.
 	var eol bool
@@ -137,111 +207,265 @@ func (c *Session) receive() error {
 		return 0, nil, nil
 	}
 
-	scanner := bufio.NewScanner(c.conn)
+	scanner := bufio.NewScanner(conn)
 	scanner.Split(split)
 
-	logch, err := c.startLogWriter()
-	if err != nil {
-		return err
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		c.handleLine(line, eol, func(l *mud.Line) { logch <- l })
 	}
+	return scanner.Err()
+}
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		logch <- line
+// handleLine displays a line of output from the server, after any
+// replacements, highlights and gags, and runs the triggers that match it.
+// eol is false for a line ended by a prompt marker instead of a newline.
+// Lines other than prompts are passed to logLine.
+func (c *Session) handleLine(b []byte, eol bool, logLine func(*mud.Line)) {
+	// Patterns are matched against a copy of the line without ANSI
+	// escape sequences; the original is what's displayed.
+	raw := mud.NewLine(b)
 
-		c.RLock()
-		if s, ok := c.replace(line); ok {
-			line = s
-		} else if s, ok := c.highlight(line); ok {
-			line = s
-		}
-		if !c.gag(line) {
-			fmt.Fprint(c.output, string(line))
-			if eol {
-				fmt.Fprintln(c.output)
-			}
+	c.RLock()
+	prompt := c.isPrompt(raw.Plain, eol)
+	c.RUnlock()
+	if prompt {
+		c.setPrompt(raw.Plain)
+	} else {
+		// keep prompts out of the logs
+		logLine(raw)
+	}
+
+	c.RLock()
+	line := raw.Raw
+	assigned := make(map[string]string)
+	if s, ok := c.replace(line, assigned); ok {
+		line = s
+	} else if s, ok := c.highlight(line); ok {
+		line = s
+	}
+	shown := mud.NewLine(line).Plain
+	if !c.gag(shown) {
+		fmt.Fprint(c.output, string(line))
+		if eol {
+			fmt.Fprintln(c.output)
 		}
-		c.triggers(line)
-		c.RUnlock()
 	}
-	return scanner.Err()
+	c.RUnlock()
+	c.assign(assigned)
+
+	c.RLock()
+	// Without a prompt pattern, a line might only look like a prompt
+	// because the rest of it was slow to arrive, e.g. "Press RETURN to
+	// continue", so it goes to the regular triggers too.
+	regular := !prompt || c.cfg.Prompt == ""
+	stopped := false
+	if prompt {
+		c.fire(shown, c.cfg.PromptTriggers, nil)
+	}
+	if regular {
+		c.recent.add(shown)
+		stopped = c.fire(shown, c.cfg.Triggers, &c.recent)
+	}
+	c.RUnlock()
+	if regular && !stopped {
+		c.fireOnce(shown)
+	}
+
+	c.updateAbilities(raw.Plain)
+	c.captureDump(raw.Plain, prompt)
 }
 
-func (c *Session) startLogWriter() (chan []byte, error) {
-	files := make(map[string]*os.File)
+func (c *Session) startLogWriter() (chan *mud.Line, error) {
+	files := make(map[string]io.Writer)
 	c.RLock()
-	for filename := range c.cfg.Log {
-		path := filepath.Join(c.path, filename)
-		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	for filename, v := range c.cfg.Log {
+		f, err := openLog(filepath.Join(c.path, filename), v.Color)
 		if err != nil {
+			c.RUnlock()
 			return nil, err
 		}
 		files[filename] = f
 	}
 	c.RUnlock()
 
-	ch := make(chan []byte)
+	ch := make(chan *mud.Line)
 
 	go func() {
-		for line := range ch {
-			c.RLock()
-			cfg := c.cfg.Log
-			for filename, v := range cfg {
-				for pattern, s := range v.Match {
-					if pattern.Match(line) {
-						tmpls := strings.Split(s, ";")
-						c.RUnlock()
-						for _, tmpl := range tmpls {
-							tmpl = strings.TrimSpace(tmpl)
-							f := files[filename]
-							if v.Timestamp {
-								fmt.Fprintf(f, "%s ", time.Now().Format(time.Kitchen))
-							}
-							fmt.Fprintln(f, pattern.Expand(line, tmpl))
+		for l := range ch {
+			c.writeLogs(files, l)
+		}
+	}()
+	return ch, nil
+}
+
+// writeLogs writes the expansions of the log patterns that match l to the
+// log files.
+func (c *Session) writeLogs(files map[string]io.Writer, l *mud.Line) {
+	line := l.Plain
+	c.RLock()
+	cfg := c.cfg.Log
+	filenames := make([]string, 0, len(cfg))
+	for filename := range cfg {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		v := cfg[filename]
+		f, ok := files[filename]
+		if !ok {
+			// added to the config since the logs were opened
+			continue
+		}
+		for _, pattern := range sortedPatterns(v.Match) {
+			if pattern.Match(line) {
+				tmpls := strings.Split(v.Match[pattern], ";")
+				c.RUnlock()
+				for _, tmpl := range tmpls {
+					tmpl = strings.TrimSpace(tmpl)
+					var ts string
+					if v.Timestamp {
+						ts = time.Now().Format(time.Kitchen) + " "
+					}
+					switch v.Color {
+					case "keep":
+						expanded := pattern.ExpandRaw(l, tmpl)
+						if strings.Contains(expanded, "\x1b") {
+							// don't let colors run into the next line
+							expanded += "\x1b[0m"
 						}
-						c.RLock()
+						fmt.Fprintln(f, ts+expanded)
+					case "html":
+						expanded := mud.NewLine([]byte(pattern.ExpandRaw(l, tmpl)))
+						fmt.Fprintln(f, html.EscapeString(ts)+expanded.HTML())
+					default:
+						fmt.Fprintln(f, ts+pattern.Expand(line, tmpl))
 					}
 				}
+				c.RLock()
 			}
-			c.RUnlock()
 		}
-	}()
-	return ch, nil
+	}
+	c.RUnlock()
+}
+
+// checkLogColor checks the color setting of a log.
+func checkLogColor(color string) error {
+	switch color {
+	case "", "strip", "keep", "html":
+		return nil
+	}
+	return fmt.Errorf("unknown color %q", color)
 }
 
-func (c *Session) triggers(line []byte) {
-	f := func(line []byte, m map[mud.Pattern]string, oneTime bool) {
-		if c.triggersDisabled {
-			return
+// openLog opens a log file for appending. A new HTML log starts with a
+// header.
+func openLog(path, color string) (*os.File, error) {
+	if err := checkLogColor(color); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if color == "html" {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
 		}
-		for pattern := range m {
-			if pattern.Match(line) {
-				template := m[pattern]
+		if fi.Size() == 0 {
+			if _, err := f.WriteString(mud.HTMLHeader); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
 
-				if oneTime {
-					delete(m, pattern)
-				}
+// fire runs the triggers that match line, in order, until one that stops
+// later triggers from matching, and reports whether one did. Multi-line
+// triggers match the most recent lines in recent, which ends with line, and
+// are skipped if recent is nil. The caller must hold the read lock, which is
+// released while commands run.
+func (c *Session) fire(line []byte, triggers mud.Triggers, recent *recentLines) bool {
+	if c.triggersDisabled {
+		return false
+	}
+	for _, t := range triggers {
+		if !c.groupEnabled(t.Group) {
+			continue
+		}
+		pattern, text := t.Pattern, line
+		if len(t.Lines) > 0 {
+			if recent == nil {
+				continue
+			}
+			pattern = t.Sequence()
+			text = recent.window(pattern, t.Window())
+		}
+		if !pattern.Match(text) {
+			continue
+		}
+		if len(t.Lines) > 0 {
+			recent.use(pattern)
+		}
+		if vars := t.Assign.Vars(pattern.Captures(text)); len(vars) > 0 {
+			c.RUnlock()
+			c.assign(vars)
+			c.RLock()
+		}
 
-				// expand regexp capture groups
-				s := pattern.Expand(line, template)
+		// expand regexp capture groups
+		s := pattern.Expand(text, t.Do)
 
-				info.Fprintf(c.output, "[trigger: %s]\n", s)
+		info.Fprintf(c.output, "[trigger: %s]\n", s)
 
-				// expand aliases
-				for _, sub := range c.expand(s) {
-					c.RUnlock()
-					ok := c.command(sub)
-					c.RLock()
-					if !ok {
-						fmt.Fprintln(c.conn, sub)
-					}
-				}
+		// expand aliases
+		for _, sub := range c.expand(s) {
+			c.RUnlock()
+			ok := c.command(sub)
+			c.RLock()
+			if !ok {
+				fmt.Fprintln(c.conn, sub)
 			}
 		}
+		if t.Stop {
+			return true
+		}
 	}
+	return false
+}
 
-	f(line, c.cfg.Triggers, false)   // permanently configured triggers
-	f(line, c.oneTimeTriggers, true) // ad-hoc one-time triggers
+// fireOnce runs and removes the one-time triggers that match line, in the
+// order they were added.
+func (c *Session) fireOnce(line []byte) {
+	c.Lock()
+	if c.triggersDisabled {
+		c.Unlock()
+		return
+	}
+	var cmds []string
+	remaining := c.oneTimeTriggers[:0]
+	for _, t := range c.oneTimeTriggers {
+		if !t.Pattern.Match(line) {
+			remaining = append(remaining, t)
+			continue
+		}
+		s := t.Pattern.Expand(line, t.Do)
+		info.Fprintf(c.output, "[trigger: %s]\n", s)
+		cmds = append(cmds, c.expand(s)...)
+	}
+	c.oneTimeTriggers = remaining
+	c.Unlock()
+
+	for _, sub := range cmds {
+		if !c.command(sub) {
+			fmt.Fprintln(c.conn, sub)
+		}
+	}
 }
 
 func (c *Session) gag(line []byte) bool {
@@ -253,43 +477,76 @@ func (c *Session) gag(line []byte) bool {
 	return false
 }
 
-func (c *Session) replace(line []byte) ([]byte, bool) {
+// replace applies the replacements that match line, adding the variables
+// they assign to vars.
+func (c *Session) replace(line []byte, vars map[string]string) ([]byte, bool) {
 	ok := false
-	for pattern, replace := range c.cfg.Replace {
-		if pattern.Match(line) {
+	for _, r := range c.cfg.Replace {
+		plain := mud.NewLine(line).Plain
+		if s, replaced := r.Pattern.Replace(line, r.With, r.Color); replaced {
 			ok = true
-			sprint := fmt.Sprint
-			if replace.Color != nil {
-				sprint = replace.Color.Sprint
+			line = s
+			for name, value := range r.Assign.Vars(r.Pattern.Captures(plain)) {
+				vars[name] = value
 			}
-			line = []byte(sprint(pattern.Expand(line, replace.With)))
 		}
 	}
 	return line, ok
 }
 
+// assign sets variables.
+func (c *Session) assign(vars map[string]string) {
+	if len(vars) == 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for name, value := range vars {
+		c.vars[name] = value
+	}
+}
+
 func (c *Session) highlight(line []byte) ([]byte, bool) {
 	if len(line) == 0 {
 		return line, false
 	}
 
 	ok := false
-	for pattern, color := range c.cfg.Highlight {
-		if pattern.Match(line) {
+	for _, h := range c.cfg.Highlight {
+		if c.groupEnabled(h.Group) && h.Pattern.Match(mud.NewLine(line).Plain) {
 			ok = true
-			line = pattern.Color(line, color)
+			line = h.Pattern.Color(line, h.Color)
 		}
 	}
 	return line, ok
 }
 
-func (c *Session) isPrompt(line []byte) bool {
+// isPrompt reports whether line is a prompt. If no prompt pattern is
+// configured, any line that was ended by a prompt marker instead of a
+// newline is taken to be a prompt, though it is still passed to the regular
+// triggers. The caller must hold the lock.
+func (c *Session) isPrompt(line []byte, eol bool) bool {
 	if c.cfg.Prompt == "" {
-		return false
+		return !eol
 	}
 	return c.cfg.Prompt.Match(line)
 }
 
+// setPrompt makes the prompt available as $prompt, and the named capture
+// groups of the prompt pattern as variables of the same names.
+func (c *Session) setPrompt(line []byte) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.vars["prompt"] = string(line)
+	if c.cfg.Prompt == "" {
+		return
+	}
+	for name, value := range c.cfg.Prompt.Captures(line) {
+		c.vars[name] = value
+	}
+}
+
 func (c *Session) send() error {
 	scanner := bufio.NewScanner(c.input)
 	for scanner.Scan() {
@@ -317,8 +574,9 @@ func (c *Session) send() error {
 					needPrompt = true
 				}
 			} else {
-				fmt.Fprintln(c.conn, sub)
-				if !quiet {
+				if _, err := fmt.Fprintln(c.conn, sub); err != nil {
+					info.Fprintf(c.output, "[%v]\n", err)
+				} else if !quiet {
 					fmt.Fprintln(c.output, sub)
 				}
 			}
@@ -338,6 +596,11 @@ func (c *Session) command(s string) bool {
 		return false
 	}
 
+	if c.replaying && sideEffects(s) {
+		fmt.Fprintln(c.conn, s)
+		return true
+	}
+
 	switch s[0] {
 	// shell command
 	case '!':
@@ -363,6 +626,16 @@ func (c *Session) command(s string) bool {
 	return true
 }
 
+// sideEffects reports whether the command runs a shell command, writes to
+// the session directory or blocks, which mud test doesn't do.
+func sideEffects(s string) bool {
+	if strings.HasPrefix(s, "!") {
+		return true
+	}
+	fields := strings.Fields(s)
+	return len(fields) > 0 && (fields[0] == "/dump" || fields[0] == "/wait")
+}
+
 func (c *Session) sys(command string) {
 	args := strings.Fields(command)
 	if len(args) == 0 {
@@ -376,7 +649,18 @@ func (c *Session) sys(command string) {
 	}
 }
 
+// env returns the environment for interpolating $variables. The caller must
+// hold the lock.
+func (c *Session) env() env {
+	return env{vars: c.vars, gmcp: c.gmcp, msdp: c.msdp, abilities: c.abilities}
+}
+
 func (c *Session) expand(s string) []string {
+	return c.expandEnv(s, c.env())
+}
+
+// expandEnv is like expand, but interpolates variables from e.
+func (c *Session) expandEnv(s string, e env) []string {
 	var out []string
 	for _, sub := range strings.Split(s, ";") {
 		sub := strings.TrimSpace(sub)
@@ -387,22 +671,22 @@ func (c *Session) expand(s string) []string {
 
 		// replace aliases
 		words := strings.Fields(sub)
-		if v, ok := c.cfg.Aliases[words[0]]; ok {
-			// info.Fprintf(c.output, "[alias: %s]\n", v)
-			sub = v
+		if alias, ok := c.cfg.Aliases[words[0]]; ok && c.groupEnabled(alias.Group) {
+			// info.Fprintf(c.output, "[alias: %s]\n", alias.Do)
+			sub = alias.Do
 		}
 
 		// interpolate $vars in the command. $1, $*, are expanded as positional
 		// parameters (useful for aliases) and other variables are expanded with
 		// their configured values.
 		var err error
-		sub, err = interpolate.Interpolate(c.vars, words, sub)
+		sub, err = interpolate.Interpolate(e, words, sub)
 		if err != nil {
 			info.Fprintf(c.output, "[ERROR: %v]\n", err)
 		}
 
 		if strings.Contains(sub, ";") {
-			out = append(out, c.expand(sub)...)
+			out = append(out, c.expandEnv(sub, e)...)
 		} else {
 			out = append(out, sub)
 		}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnjackins/mud"
+)
+
+// A syncBuffer is a bytes.Buffer that timers can write to while a test reads
+// it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// newTestSession returns a session with the given configuration, which
+// writes what it displays and what it sends ("> cmd") to out.
+func newTestSession(t *testing.T, config string) (sess *Session, out *syncBuffer) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mud.UnmarshalConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	color.NoColor = true
+
+	out = new(syncBuffer)
+	sess = &Session{
+		prefix: "test",
+		path:   dir,
+		conn:   &link{record: prefixWriter{"> ", out}},
+		output: pipe{w: nopCloser{out}},
+
+		vars:           make(mapvars),
+		gmcp:           make(map[string]interface{}),
+		msdp:           make(map[string]interface{}),
+		lists:          make(map[string][]string),
+		disabledGroups: make(map[string]bool),
+		abilities:      make(map[string]bool),
+		whenReady:      make(map[string][]string),
+	}
+	sess.SetConfig(cfg)
+	t.Cleanup(func() { sess.cancelTimers() })
+	return sess, out
+}
+
+func TestUnmarkedPrompt(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   string
+	}{
+		"no prompt pattern": {
+			config: "triggers:\n  Press RETURN: ok\nprompt_triggers:\n  continue: look\n",
+			want:   "Press RETURN to continue[trigger: look]\n> look\n[trigger: ok]\n> ok\n",
+		},
+		"prompt pattern": {
+			config: "prompt: continue$\ntriggers:\n  Press RETURN: ok\nprompt_triggers:\n  continue: look\n",
+			want:   "Press RETURN to continue[trigger: look]\n> look\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sess, out := newTestSession(t, test.config)
+			sess.handleLine([]byte("Press RETURN to continue"), false, func(*mud.Line) {})
+			if diff := cmp.Diff(test.want, out.String()); diff != "" {
+				t.Errorf("output mismatch: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+type sessionState int
+
+const (
+	stateConnecting sessionState = iota
+	stateConnected
+	stateDisconnected
+	stateClosed
+)
+
+func (s sessionState) String() string {
+	switch s {
+	case stateConnecting:
+		return "connecting"
+	case stateConnected:
+		return "connected"
+	case stateDisconnected:
+		return "disconnected"
+	case stateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("sessionState(%d)", int(s))
+}
+
+type sessionStatus struct {
+	state sessionState
+	err   error
+	since time.Time
+}
+
+// parseSession parses a session argument of the form prefix:path.
+func parseSession(arg string) (prefix, path string, err error) {
+	parts := strings.Split(arg, ":")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("bad session %q", arg)
+	}
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("empty session prefix")
+	}
+	return parts[0], parts[1], nil
+}
+
+// setState records a change in the state of sess, logging it if the session
+// failed.
+func (c *client) setState(sess *Session, state sessionState, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.sessions[sess.prefix] != sess {
+		// removed with /close
+		return
+	}
+	if st, ok := c.status[sess]; ok && st.state == stateClosed {
+		// a closed session can't come back
+		return
+	}
+	c.status[sess] = &sessionStatus{state: state, err: err, since: time.Now()}
+	if state == stateClosed && err != nil {
+		log.Printf("%s: %v", sess.prefix, err)
+	}
+}
+
+// session returns the session with the given prefix.
+func (c *client) session(prefix string) (*Session, bool) {
+	c.Lock()
+	defer c.Unlock()
+	sess, ok := c.sessions[prefix]
+	return sess, ok
+}
+
+// mainSession returns the session that input without a prefix goes to, which
+// is nil if there are no sessions.
+func (c *client) mainSession() *Session {
+	c.Lock()
+	defer c.Unlock()
+	return c.main
+}
+
+func (c *client) setMain(sess *Session) {
+	c.Lock()
+	c.main = sess
+	c.Unlock()
+}
+
+// closeSession closes the session with the given prefix and removes it from
+// the client.
+func (c *client) closeSession(prefix string) error {
+	c.Lock()
+	sess, ok := c.sessions[prefix]
+	if !ok {
+		c.Unlock()
+		return fmt.Errorf("no session %q", prefix)
+	}
+	delete(c.sessions, prefix)
+	delete(c.status, sess)
+	if c.main == sess {
+		c.main = nil
+		for _, p := range c.prefixes() {
+			c.main = c.sessions[p]
+			break
+		}
+	}
+	c.Unlock()
+
+	info.Fprintf(sess.output, "[session closed]\n")
+	return sess.Close()
+}
+
+// closeAll closes every session.
+func (c *client) closeAll() {
+	c.Lock()
+	var prefixes []string
+	for prefix := range c.sessions {
+		prefixes = append(prefixes, prefix)
+	}
+	c.Unlock()
+
+	for _, prefix := range prefixes {
+		c.closeSession(prefix)
+	}
+}
+
+// prefixes returns the prefixes of all sessions, sorted. The caller must hold
+// the lock.
+func (c *client) prefixes() []string {
+	var prefixes []string
+	for prefix := range c.sessions {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// listSessions writes the state of each session to w.
+func (c *client) listSessions(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, prefix := range c.prefixes() {
+		sess := c.sessions[prefix]
+		mark := " "
+		if sess == c.main {
+			mark = "*"
+		}
+		st, ok := c.status[sess]
+		if !ok {
+			fmt.Fprintf(w, "%s %s\t%s\n", mark, prefix, sess.path)
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s since %s", mark, prefix, sess.path, st.state, st.since.Format(time.Kitchen))
+		if st.err != nil {
+			fmt.Fprintf(w, ": %v", st.err)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// setState records a change in the state of the session's connection.
+func (c *Session) setState(state sessionState, err error) {
+	if c.client != nil {
+		c.client.setState(c, state, err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// since matches the time in a line of /sessions.
+var since = regexp.MustCompile(` since [0-9:]+[AP]M`)
+
+func TestSessionLifecycle(t *testing.T) {
+	// a server that keeps each connection open until the client closes it
+	holdConn := func(conn net.Conn) {
+		ioutil.ReadAll(conn)
+		conn.Close()
+	}
+
+	tests := map[string]struct {
+		handle func(net.Conn)
+		// commands run in session a, the main session; $b is the
+		// directory of a second session
+		cmds []string
+		want string
+	}{
+		"connected": {
+			handle: holdConn,
+			want:   "* a\t$a\tconnected\n",
+		},
+		"dropped": {
+			handle: closeConn,
+			want:   "* a\t$a\tdisconnected: connection closed\n",
+		},
+		"connect": {
+			handle: holdConn,
+			cmds:   []string{"/connect b:$b"},
+			want:   "* a\t$a\tconnected\n  b\t$b\tconnected\n",
+		},
+		"connect existing": {
+			handle: holdConn,
+			cmds:   []string{"/connect a:$b"},
+			want:   "* a\t$a\tconnected\n",
+		},
+		"close other": {
+			handle: holdConn,
+			cmds:   []string{"/connect b:$b", "/close b"},
+			want:   "* a\t$a\tconnected\n",
+		},
+		"close main": {
+			handle: holdConn,
+			cmds:   []string{"/connect b:$b", "/close a"},
+			want:   "* b\t$b\tconnected\n",
+		},
+		"disconnect": {
+			handle: holdConn,
+			cmds:   []string{"/disconnect"},
+			want:   "* a\t$a\tdisconnected: use of closed network connection\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addr := listen(t, test.handle)
+			config := fmt.Sprintf("address: %s\nreconnect:\n  disable: true\n", addr)
+			dirs := map[string]string{"a": t.TempDir(), "b": t.TempDir()}
+			for _, dir := range dirs {
+				if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expand := func(s string) string {
+				return strings.NewReplacer("$a", dirs["a"], "$b", dirs["b"]).Replace(s)
+			}
+
+			c := &client{
+				sessions: make(map[string]*Session),
+				status:   make(map[*Session]*sessionStatus),
+				serving:  true,
+			}
+			defer c.closeAll()
+			a, err := c.startSession("a", dirs["a"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitDialed(t, c, "a")
+			for _, cmd := range test.cmds {
+				a.command(expand(cmd))
+				if strings.HasPrefix(cmd, "/connect b:") {
+					waitDialed(t, c, "b")
+				}
+			}
+
+			// the state settles once the connections are up or down
+			want := expand(test.want)
+			var got string
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				var buf strings.Builder
+				c.listSessions(&buf)
+				if got = since.ReplaceAllString(buf.String(), ""); got == want {
+					break
+				}
+			}
+			if got != want {
+				t.Errorf("got sessions:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// waitDialed waits for the session with the given prefix to be done
+// connecting to the server.
+func waitDialed(t *testing.T, c *client, prefix string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		c.Lock()
+		sess := c.sessions[prefix]
+		st, ok := c.status[sess]
+		reached := ok && st.state != stateConnecting
+		c.Unlock()
+		if reached {
+			return
+		}
+	}
+	t.Fatalf("session %s still connecting", prefix)
+}
@@ -9,18 +9,24 @@ func (c *Session) startTimers() context.CancelFunc {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	for _, t := range c.cfg.Timers {
-		c.startTimer(ctx, t.Every, t.Do)
+		c.startTimer(ctx, t.Every, t.Do, t.Group)
 	}
 	return cancel
 }
 
-func (c *Session) startTimer(ctx context.Context, d time.Duration, cmd string) {
+func (c *Session) startTimer(ctx context.Context, d time.Duration, cmd, group string) {
 	ticker := time.NewTicker(d)
 	cmds := c.expand(cmd)
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
+				c.RLock()
+				enabled := c.groupEnabled(group)
+				c.RUnlock()
+				if !enabled {
+					continue
+				}
 				for _, s := range cmds {
 					c.conn.Write([]byte(s + "\n"))
 				}
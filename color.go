@@ -33,7 +33,7 @@ func (c *Color) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	case "white":
 		c.Color = color.New(color.FgHiWhite, color.Bold)
 	default:
-		return fmt.Errorf("unknown color %v", s)
+		return fmt.Errorf("unknown color %q", s)
 	}
 
 	return nil
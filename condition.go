@@ -0,0 +1,109 @@
+package mud
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Condition compares a named field to a value, e.g. "hp < 100" or
+// "name == Bob". A Condition with only a field, e.g. "fighting", holds if the
+// field is set to something other than "", "0" or "false".
+type Condition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// operators, longest first so that "<=" is not parsed as "<".
+var operators = []string{"<=", ">=", "==", "!=", "<", ">", "="}
+
+// ParseCondition parses a condition of the form "field [op value]", where op
+// is one of <, <=, >, >=, == (or =) and !=. The condition is split at the
+// first operator, so the value may contain operators but the field may not.
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	for i := range s {
+		for _, op := range operators {
+			if !strings.HasPrefix(s[i:], op) {
+				continue
+			}
+			c := Condition{
+				Field: strings.TrimSpace(s[:i]),
+				Op:    op,
+				Value: strings.TrimSpace(s[i+len(op):]),
+			}
+			if c.Op == "=" {
+				c.Op = "=="
+			}
+			if c.Field == "" {
+				return c, fmt.Errorf("condition %q: missing field", s)
+			}
+			return c, nil
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return Condition{}, fmt.Errorf("bad condition %q", s)
+	}
+	return Condition{Field: s}, nil
+}
+
+func (c *Condition) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	cond, err := ParseCondition(s)
+	if err != nil {
+		return err
+	}
+	*c = cond
+	return nil
+}
+
+func (c Condition) String() string {
+	if c.Op == "" {
+		return c.Field
+	}
+	return c.Field + " " + c.Op + " " + c.Value
+}
+
+// Holds reports whether the condition holds, given the value of its field
+// and the value to compare it with (normally c.Value, after expanding any
+// variables). Values that both parse as numbers are compared numerically,
+// and anything else is compared as strings.
+func (c Condition) Holds(field, value string) bool {
+	if c.Op == "" {
+		return field != "" && field != "0" && field != "false"
+	}
+
+	var cmp int
+	x, xerr := strconv.ParseFloat(field, 64)
+	y, yerr := strconv.ParseFloat(value, 64)
+	if xerr == nil && yerr == nil {
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(field, value)
+	}
+
+	switch c.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package mud
+
+import "testing"
+
+func TestCondition(t *testing.T) {
+	tests := map[string]struct {
+		cond  string
+		field string
+		value string
+		holds bool
+	}{
+		"less":             {cond: "hp < 100", field: "95", holds: true},
+		"not less":         {cond: "hp < 100", field: "100", holds: false},
+		"numeric":          {cond: "hp <= 100", field: "99.5", holds: true},
+		"greater or equal": {cond: "hp >= 100", field: "100", holds: true},
+		"not lexical":      {cond: "hp > 9", field: "10", holds: true},
+		"equal":            {cond: "name == Bob", field: "Bob", holds: true},
+		"single equals":    {cond: "name = Bob", field: "Bob", holds: true},
+		"not equal":        {cond: "name != Bob", field: "Alice", holds: true},
+		"truthy":           {cond: "fighting", field: "true", holds: true},
+		"falsy":            {cond: "fighting", field: "false", holds: false},
+		"zero":             {cond: "fighting", field: "0", holds: false},
+		"expanded value":   {cond: "hp < $min", field: "40", value: "50", holds: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond, err := ParseCondition(test.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value := cond.Value
+			if test.value != "" {
+				value = test.value
+			}
+			if got := cond.Holds(test.field, value); got != test.holds {
+				t.Errorf("%q.Holds(%q, %q) = %v, want %v", test.cond, test.field, value, got, test.holds)
+			}
+		})
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := map[string]struct {
+		cond string
+		want Condition
+	}{
+		"spaced":            {cond: "hp < 100", want: Condition{Field: "hp", Op: "<", Value: "100"}},
+		"unspaced":          {cond: "hp<=100", want: Condition{Field: "hp", Op: "<=", Value: "100"}},
+		"longest operator":  {cond: "hp >= 100", want: Condition{Field: "hp", Op: ">=", Value: "100"}},
+		"leftmost operator": {cond: "x < a==b", want: Condition{Field: "x", Op: "<", Value: "a==b"}},
+		"operator in value": {cond: "name != a<b", want: Condition{Field: "name", Op: "!=", Value: "a<b"}},
+		"single equals":     {cond: "name = Bob", want: Condition{Field: "name", Op: "==", Value: "Bob"}},
+		"field only":        {cond: "fighting", want: Condition{Field: "fighting"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseCondition(test.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,42 +1,120 @@
 package mud
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp/syntax"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Address string
-	Login   struct {
+	Address     string
+	TLS         bool   `yaml:"tls,omitempty"`
+	TLSCA       string `yaml:"tls_ca,omitempty"`
+	TLSInsecure bool   `yaml:"tls_insecure,omitempty"`
+	Reconnect   struct {
+		// Min and Max bound the delay between reconnection attempts, which
+		// doubles after each failure or connection that drops within a
+		// minute.
+		Min     time.Duration
+		Max     time.Duration
+		Disable bool
+	} `yaml:"reconnect,omitempty"`
+	Login struct {
 		Name     string
 		Password string
 	}
-	Prompt    Pattern
-	Abilities map[string]struct {
-		Ready []string
-		Wait  []string
-	}
-	Triggers map[Pattern]string
-	Vars     map[string]string
-	Lists    map[string][]string
-	Aliases  map[string]string
-	Log      map[string]struct {
-		Timestamp bool               `yaml:"timestamp,omitempty"`
-		Match     map[Pattern]string `yaml:"match,omitempty"`
+	GMCP struct {
+		Client   string
+		Version  string
+		Supports []string
+	} `yaml:"gmcp,omitempty"`
+	MSDP struct {
+		Report []string
+	} `yaml:"msdp,omitempty"`
+	Terminal struct {
+		Type   string
+		Width  int
+		Height int
+		MTTS   []string
+	} `yaml:"terminal,omitempty"`
+	// Prompt matches the prompt. Its named capture groups, e.g.
+	// (?P<hp>[0-9]+), are set as variables whenever a prompt arrives.
+	Prompt Pattern
+	// PromptDetection is how prompts without a newline are recognized:
+	// "ga" for only those followed by GA or EOR, "timeout" to also take a
+	// line to be a prompt if the rest of it doesn't arrive within
+	// PromptTimeout, or "regex" to also take a line matching Prompt to be a
+	// prompt as soon as it arrives. The default is "regex" if Prompt is set
+	// and "timeout" otherwise.
+	PromptDetection string        `yaml:"prompt_detection,omitempty"`
+	PromptTimeout   time.Duration `yaml:"prompt_timeout,omitempty"`
+	Abilities       map[string]struct {
+		// Ready and Wait are patterns for output showing that the ability
+		// can be used again, or that it is cooling down.
+		Ready []Pattern
+		Wait  []Pattern
+	}
+	// AbilityLog is the file in the session directory that ability status
+	// lines such as "bash up" are appended to, for cmd/status.
+	AbilityLog string `yaml:"ability_log,omitempty"`
+	Triggers   Triggers
+	// PromptTriggers are like triggers, but match only prompts, which
+	// regular triggers never see.
+	PromptTriggers Triggers      `yaml:"prompt_triggers,omitempty"`
+	GMCPTriggers   []GMCPTrigger `yaml:"gmcp_triggers,omitempty"`
+	Vars           map[string]string
+	Lists          map[string][]string
+	Aliases        map[string]Alias
+	Log            map[string]struct {
+		Timestamp bool `yaml:"timestamp,omitempty"`
+		// Color is what to do with the colors the server sent: "strip"
+		// them (the default), "keep" the ANSI escape sequences, or
+		// write an "html" transcript.
+		Color string             `yaml:"color,omitempty"`
+		Match map[Pattern]string `yaml:"match,omitempty"`
 	} `yaml:"log,omitempty"`
 	Dump      map[string]*DumpConfig
-	Highlight map[Pattern]*Color
-	Replace   map[Pattern]struct {
-		With  string
-		Color *Color
-	}
-	Gag    []Pattern
-	Timers []struct {
+	Highlight Highlights
+	Replace   Replacements
+	Gag       []Pattern
+	Timers    []struct {
 		Every time.Duration
 		Do    string
+		Group string `yaml:"group,omitempty"`
+	}
+}
+
+// A GMCPTrigger runs a command when a GMCP message arrives from the server.
+type GMCPTrigger struct {
+	// Package is the GMCP package to watch for, e.g. "Char.Vitals". It also
+	// matches packages nested within it.
+	Package string
+	// If is an optional condition on a field of the message payload.
+	If *Condition `yaml:"if,omitempty"`
+	Do string
+}
+
+// An Alias replaces the first word of a command. It is written either as
+// just the replacement, or as a mapping with a group.
+type Alias struct {
+	Do    string
+	Group string `yaml:"group,omitempty"`
+}
+
+func (a *Alias) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&a.Do); err == nil {
+		return nil
 	}
+	type alias Alias // without this method
+	return unmarshal((*alias)(a))
 }
 
 type DumpConfig struct {
@@ -45,7 +123,33 @@ type DumpConfig struct {
 	Match map[Pattern]string
 }
 
+// File returns the file, relative to the session directory, that the dump
+// with the given name writes to.
+func (d *DumpConfig) File(name string) string {
+	if d == nil || d.Dest == "" {
+		return name
+	}
+	return d.Dest
+}
+
+// insideDir reports whether the relative path stays inside the directory it
+// is relative to.
+func insideDir(path string) bool {
+	path = filepath.Clean(path)
+	return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, ".."+string(filepath.Separator))
+}
+
 func UnmarshalConfig(path string) (Config, error) {
+	return unmarshalConfig(path, yaml.Unmarshal)
+}
+
+// UnmarshalConfigStrict is like UnmarshalConfig, except that keys that
+// aren't part of the config are errors.
+func UnmarshalConfigStrict(path string) (Config, error) {
+	return unmarshalConfig(path, yaml.UnmarshalStrict)
+}
+
+func unmarshalConfig(path string, unmarshal func([]byte, interface{}) error) (Config, error) {
 	var cfg Config
 
 	buf, err := ioutil.ReadFile(path)
@@ -53,6 +157,100 @@ func UnmarshalConfig(path string) (Config, error) {
 		return cfg, err
 	}
 
-	err = yaml.Unmarshal(buf, &cfg)
-	return cfg, err
+	if err := unmarshal(buf, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.checkPatterns()
+}
+
+// A PatternError is an invalid pattern in a section of the config.
+type PatternError struct {
+	Section string
+	Pattern Pattern
+	Err     error
+}
+
+func (e *PatternError) Error() string {
+	msg := e.Err.Error()
+	var serr *syntax.Error
+	if errors.As(e.Err, &serr) {
+		msg = serr.Code.String()
+	}
+	return fmt.Sprintf("%s: bad pattern %q: %s", e.Section, e.Pattern, msg)
+}
+
+// ConfigErrors lists the problems found in a config.
+type ConfigErrors []error
+
+func (errs ConfigErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// checkPatterns compiles every pattern in the config, returning a
+// ConfigErrors listing any that are invalid.
+func (cfg *Config) checkPatterns() error {
+	var errs ConfigErrors
+	check := func(section string, patterns ...Pattern) {
+		for _, p := range patterns {
+			if _, err := p.Regexp(); err != nil {
+				errs = append(errs, &PatternError{Section: section, Pattern: p, Err: err})
+			}
+		}
+	}
+
+	check("prompt", cfg.Prompt)
+	for _, name := range sortedKeys(cfg.Abilities) {
+		ability := cfg.Abilities[name]
+		check("abilities "+name, append(ability.Ready, ability.Wait...)...)
+	}
+	for _, t := range cfg.Triggers {
+		check("triggers", append([]Pattern{t.Pattern}, t.Lines...)...)
+	}
+	for _, t := range cfg.PromptTriggers {
+		check("prompt_triggers", append([]Pattern{t.Pattern}, t.Lines...)...)
+	}
+	for _, name := range sortedKeys(cfg.Log) {
+		for _, p := range sortedKeys(cfg.Log[name].Match) {
+			check("log "+name, Pattern(p))
+		}
+	}
+	for _, name := range sortedKeys(cfg.Dump) {
+		if d := cfg.Dump[name]; d != nil {
+			for _, p := range sortedKeys(d.Match) {
+				check("dump "+name, Pattern(p))
+			}
+		}
+		if dest := cfg.Dump[name].File(name); !insideDir(dest) {
+			errs = append(errs, fmt.Errorf("dump %s: %q is outside the session directory", name, dest))
+		}
+	}
+	for _, h := range cfg.Highlight {
+		check("highlight", h.Pattern)
+		if h.Color == nil {
+			errs = append(errs, fmt.Errorf("highlight: %q has no color", h.Pattern))
+		}
+	}
+	for _, r := range cfg.Replace {
+		check("replace", r.Pattern)
+	}
+	check("gag", cfg.Gag...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m, a map with string keys, in order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
 }
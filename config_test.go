@@ -0,0 +1,75 @@
+package mud
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAlias(t *testing.T) {
+	var got map[string]Alias
+	data := "mm: c 'magic missile' $1\nk:\n  do: kill $1\n  group: combat\n"
+	if err := yaml.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Alias{
+		"mm": {Do: "c 'magic missile' $1"},
+		"k":  {Do: "kill $1", Group: "combat"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("aliases mismatch: %v", diff)
+	}
+}
+
+func TestPatternErrors(t *testing.T) {
+	data := `
+prompt: '<(?P<hp>[0-9]+hp>'
+triggers:
+  ok: fine
+  '(foo': bar
+gag:
+  - '[a-'
+highlight:
+  'glob:(': red
+  plain:
+log:
+  chat:
+    match:
+      'x**': $0
+dump:
+  skills:
+    match:
+      'a)': $0
+  spells:
+    dest: ../spells.txt
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := UnmarshalConfig(path)
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("got error %v, want ConfigErrors", err)
+	}
+
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		`prompt: bad pattern "<(?P<hp>[0-9]+hp>": missing closing )`,
+		`triggers: bad pattern "(foo": missing closing )`,
+		`log chat: bad pattern "x**": invalid nested repetition operator`,
+		`dump skills: bad pattern "a)": unexpected )`,
+		`dump spells: "../spells.txt" is outside the session directory`,
+		`highlight: "plain" has no color`,
+		`gag: bad pattern "[a-": missing closing ]`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("errors mismatch: %v", diff)
+	}
+}
@@ -0,0 +1,187 @@
+package mud
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// The 16 basic ANSI colors, as displayed by xterm.
+var ansiColors = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// HTMLHeader starts an HTML document that lines formatted with HTML are
+// written to.
+const HTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<style>
+body { background: #000; color: #e5e5e5; font-family: monospace; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+`
+
+type sgr struct {
+	fg, bg    string
+	bold      bool
+	italic    bool
+	underline bool
+}
+
+func (s sgr) css() string {
+	var props []string
+	if s.fg != "" {
+		props = append(props, "color: "+s.fg)
+	}
+	if s.bg != "" {
+		props = append(props, "background: "+s.bg)
+	}
+	if s.bold {
+		props = append(props, "font-weight: bold")
+	}
+	if s.italic {
+		props = append(props, "font-style: italic")
+	}
+	if s.underline {
+		props = append(props, "text-decoration: underline")
+	}
+	return strings.Join(props, "; ")
+}
+
+// HTML formats the line as HTML, with its ANSI colors and attributes as
+// styled spans.
+func (l *Line) HTML() string {
+	var b strings.Builder
+	var state sgr
+	var text []byte
+
+	flush := func() {
+		if len(text) == 0 {
+			return
+		}
+		escaped := html.EscapeString(string(text))
+		if css := state.css(); css != "" {
+			fmt.Fprintf(&b, `<span style="%s">%s</span>`, css, escaped)
+		} else {
+			b.WriteString(escaped)
+		}
+		text = text[:0]
+	}
+
+	for i := 0; i < len(l.Raw); {
+		if l.Raw[i] != esc {
+			text = append(text, l.Raw[i])
+			i++
+			continue
+		}
+		n := escapeLen(l.Raw[i:])
+		seq := l.Raw[i : i+n]
+		i += n
+		if len(seq) < 3 || seq[1] != '[' || seq[len(seq)-1] != 'm' {
+			continue
+		}
+		flush()
+		state = state.apply(string(seq[2 : len(seq)-1]))
+	}
+	flush()
+	return b.String()
+}
+
+// apply returns the state after the SGR sequence with the given parameters.
+func (s sgr) apply(params string) sgr {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if codes[i] == "" {
+			code, err = 0, nil
+		}
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			s = sgr{}
+		case code == 1:
+			s.bold = true
+		case code == 3:
+			s.italic = true
+		case code == 4:
+			s.underline = true
+		case code == 22:
+			s.bold = false
+		case code == 23:
+			s.italic = false
+		case code == 24:
+			s.underline = false
+		case code >= 30 && code <= 37:
+			s.fg = ansiColors[code-30]
+		case code >= 90 && code <= 97:
+			s.fg = ansiColors[code-90+8]
+		case code == 39:
+			s.fg = ""
+		case code >= 40 && code <= 47:
+			s.bg = ansiColors[code-40]
+		case code >= 100 && code <= 107:
+			s.bg = ansiColors[code-100+8]
+		case code == 49:
+			s.bg = ""
+		case code == 38 || code == 48:
+			// extended colors: 5;n or 2;r;g;b
+			var color string
+			color, i = extendedColor(codes, i+1)
+			if code == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+	return s
+}
+
+// extendedColor parses a 256-color or truecolor specification starting at
+// codes[i], and returns the color and the index of its last code.
+func extendedColor(codes []string, i int) (string, int) {
+	arg := func(j int) int {
+		if j >= len(codes) {
+			return 0
+		}
+		n, _ := strconv.Atoi(codes[j])
+		return n
+	}
+
+	switch arg(i) {
+	case 5:
+		return color256(arg(i + 1)), i + 1
+	case 2:
+		return fmt.Sprintf("#%02x%02x%02x", arg(i+1)&0xff, arg(i+2)&0xff, arg(i+3)&0xff), i + 3
+	}
+	return "", i
+}
+
+func color256(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		// 6x6x6 color cube
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
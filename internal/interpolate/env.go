@@ -9,6 +9,13 @@ type Env interface {
 	Get(key string) (string, bool)
 }
 
+// A NamespaceEnv is an Env with dotted identifiers, such as $ns.a.b, under
+// each of its namespaces.
+type NamespaceEnv interface {
+	Env
+	Namespaces() []string
+}
+
 // Creates an Env from a slice of environment variables
 func NewSliceEnv(env []string) Env {
 	envMap := mapEnv{}
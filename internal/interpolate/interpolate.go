@@ -11,7 +11,11 @@ func Interpolate(env Env, args []string, template string) (string, error) {
 	if env == nil {
 		env = NewSliceEnv(nil)
 	}
-	expr, err := NewParser(template).Parse()
+	var namespaces []string
+	if ns, ok := env.(NamespaceEnv); ok {
+		namespaces = ns.Namespaces()
+	}
+	expr, err := NewParser(template, namespaces...).Parse()
 	if err != nil {
 		return "", err
 	}
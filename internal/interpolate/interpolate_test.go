@@ -0,0 +1,61 @@
+package interpolate
+
+import "testing"
+
+// namespaceEnv is an Env with namespaces.
+type namespaceEnv struct {
+	Env
+	namespaces []string
+}
+
+func (e namespaceEnv) Namespaces() []string { return e.namespaces }
+
+func TestInterpolate(t *testing.T) {
+	vars := NewMapEnv(map[string]string{
+		"name":           "bob",
+		"gmcp.Char.hp":   "42",
+		"ability.bash":   "ready",
+		"msdp.HEALTH":    "100",
+		"name.txt":       "unused",
+		"gmcp.Char.Name": "Bob",
+	})
+	env := namespaceEnv{vars, []string{"gmcp", "msdp", "ability"}}
+	tests := map[string]struct {
+		template string
+		want     string
+	}{
+		"variable":           {"hello $name", "hello bob"},
+		"variable with ext":  {"!cp $name.txt /tmp", "!cp bob.txt /tmp"},
+		"trailing dot":       {"hi $name.", "hi bob."},
+		"braces":             {"${name}.txt", "bob.txt"},
+		"gmcp":               {"hp $gmcp.Char.hp", "hp 42"},
+		"gmcp trailing dot":  {"I am $gmcp.Char.Name.", "I am Bob."},
+		"ability":            {"bash $ability.bash", "bash ready"},
+		"msdp":               {"$msdp.HEALTH%", "100%"},
+		"argument":           {"kill $1", "kill orc"},
+		"unset with default": {"${unset:-none}", "none"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Interpolate(env, []string{"k", "orc"}, test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNoNamespaces(t *testing.T) {
+	env := NewMapEnv(map[string]string{"gmcp": "x", "gmcp.Char.hp": "42"})
+	got, err := Interpolate(env, nil, "hp $gmcp.Char.hp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hp x.Char.hp"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -25,7 +25,8 @@ import (
 /*
 EscapedBackslash = "\\"
 EscapedDollar    = ( "\$" | "$$")
-Identifier       = letter { letters | digit | "_" }
+Name             = letter { letter | digit | "_" }
+Identifier       = Name { "." Name }   (only if the first Name is a namespace)
 Expansion        = "$" ( Identifier | Brace )
 Brace            = "{" Identifier [ Identifier BraceOperation ] "}"
 Text             = { EscapedBackslash | EscapedDollar | all characters except "$" }
@@ -43,16 +44,24 @@ const (
 
 // Parser takes a string and parses out a tree of structs that represent text and Expansions
 type Parser struct {
-	input string // the string we are scanning
-	pos   int    // the current position
+	input      string          // the string we are scanning
+	pos        int             // the current position
+	namespaces map[string]bool // names that begin a dotted identifier
 }
 
-// NewParser returns a new instance of a Parser
-func NewParser(str string) *Parser {
-	return &Parser{
-		input: str,
-		pos:   0,
+// NewParser returns a new instance of a Parser. An identifier that begins
+// with one of the namespaces continues past dots, so that with the namespace
+// "a", $a.b is one identifier but $b.c is $b followed by ".c".
+func NewParser(str string, namespaces ...string) *Parser {
+	p := &Parser{
+		input:      str,
+		pos:        0,
+		namespaces: make(map[string]bool),
 	}
+	for _, ns := range namespaces {
+		p.namespaces[ns] = true
+	}
+	return p
 }
 
 // Parse expansions out of the internal text and return them as a tree of Expressions
@@ -283,10 +292,28 @@ func (p *Parser) scanIdentifier() (string, error) {
 	if c := p.peekRune(); !unicode.IsLetter(c) {
 		return "", fmt.Errorf("Expected identifier to start with a letter, got %c", c)
 	}
-	notIdentifierChar := func(r rune) bool {
-		return (!unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_')
+	isIdentifierChar := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+	}
+	start := p.pos
+	p.scanUntil(func(r rune) bool { return !isIdentifierChar(r) })
+	if !p.namespaces[p.input[start:p.pos]] {
+		return p.input[start:p.pos], nil
+	}
+	for {
+		// A dot continues the identifier only if more identifier follows,
+		// so that $ns.a is one identifier but "$ns." is $ns followed by
+		// text.
+		if !strings.HasPrefix(p.input[p.pos:], ".") {
+			break
+		}
+		if r, _ := utf8.DecodeRuneInString(p.input[p.pos+1:]); !isIdentifierChar(r) {
+			break
+		}
+		p.pos++
+		p.scanUntil(func(r rune) bool { return !isIdentifierChar(r) })
 	}
-	return p.scanUntil(notIdentifierChar), nil
+	return p.input[start:p.pos], nil
 }
 
 func (p *Parser) scanNumber() (string, error) {
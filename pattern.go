@@ -1,14 +1,69 @@
 package mud
 
 import (
-	"bytes"
-	"fmt"
 	"regexp"
+	"strings"
 	"sync"
 )
 
+// A Pattern matches lines of output. By default it is a regular expression,
+// but a prefix can give it another kind:
+//
+//	literal:text  matches text anywhere in the line
+//	glob:text     matches the whole line, with * matching anything and
+//	              capturing it as a group
+//	line:re       a regular expression matching the whole line
+//	ire:re        a case-insensitive regular expression
+//	re:re         a regular expression, as without a prefix
 type Pattern string
 
+// patternKinds maps the prefix of each kind of pattern to a function
+// returning the equivalent regular expression.
+var patternKinds = map[string]func(string) string{
+	"literal": regexp.QuoteMeta,
+	"glob":    globExpr,
+	"line":    func(s string) string { return `^(?:` + s + `)$` },
+	"ire":     func(s string) string { return `(?i)` + s },
+	"re":      func(s string) string { return s },
+}
+
+// Kind returns the kind of the pattern: "literal", "glob", "line", "ire" or
+// "re".
+func (p Pattern) Kind() string {
+	kind, _ := p.split()
+	return kind
+}
+
+// Text returns the pattern without its prefix.
+func (p Pattern) Text() string {
+	_, text := p.split()
+	return text
+}
+
+func (p Pattern) split() (kind, text string) {
+	for kind := range patternKinds {
+		if prefix := kind + ":"; strings.HasPrefix(string(p), prefix) {
+			return kind, string(p)[len(prefix):]
+		}
+	}
+	return "re", string(p)
+}
+
+// expr returns the regular expression equivalent to p.
+func (p Pattern) expr() string {
+	kind, text := p.split()
+	return patternKinds[kind](text)
+}
+
+// globExpr converts a glob to a regular expression.
+func globExpr(glob string) string {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return `^` + strings.Join(parts, `(.*)`) + `$`
+}
+
 type result struct {
 	*regexp.Regexp
 	err error
@@ -32,7 +87,7 @@ func (p Pattern) get() (*regexp.Regexp, error) {
 		return re.Regexp, re.err
 	}
 
-	compiled, err := regexp.Compile(string(p))
+	compiled, err := regexp.Compile(p.expr())
 	pcache.Lock()
 	pcache.patterns[p] = result{
 		Regexp: compiled,
@@ -42,10 +97,16 @@ func (p Pattern) get() (*regexp.Regexp, error) {
 	return compiled, err
 }
 
+// Regexp returns the compiled regular expression equivalent to p. The other
+// methods treat an invalid pattern as one that never matches, so patterns
+// from config files are checked by UnmarshalConfig.
+func (p Pattern) Regexp() (*regexp.Regexp, error) {
+	return p.get()
+}
+
 func (p Pattern) Match(s []byte) bool {
 	re, err := p.get()
 	if err != nil {
-		fmt.Println(err)
 		return false
 	}
 	return re.Match(s)
@@ -54,7 +115,6 @@ func (p Pattern) Match(s []byte) bool {
 func (p Pattern) Expand(content []byte, template string) string {
 	re, err := p.get()
 	if err != nil {
-		fmt.Println(err)
 		return ""
 	}
 
@@ -67,27 +127,97 @@ func (p Pattern) Expand(content []byte, template string) string {
 	return string(result)
 }
 
+// ExpandRaw is like Expand on line.Plain, except that the expansion of each
+// capture group keeps the ANSI escape sequences in line.Raw within it and
+// leading up to it.
+func (p Pattern) ExpandRaw(line *Line, template string) string {
+	re, err := p.get()
+	if err != nil {
+		return ""
+	}
+
+	var result []byte
+	for _, submatch := range re.FindAllSubmatchIndex(line.Plain, -1) {
+		raw := make([]int, len(submatch))
+		for i := 0; i < len(submatch); i += 2 {
+			if submatch[i] < 0 {
+				raw[i], raw[i+1] = -1, -1
+				continue
+			}
+			raw[i], raw[i+1] = line.styledSpan(submatch[i], submatch[i+1])
+		}
+		result = re.Expand(result, []byte(template), line.Raw, raw)
+	}
+	return string(result)
+}
+
+// Captures returns the values of the named capture groups in the first match
+// of p in s, or nil if p doesn't match.
+func (p Pattern) Captures(s []byte) map[string]string {
+	re, err := p.get()
+	if err != nil {
+		return nil
+	}
+
+	match := re.FindSubmatch(s)
+	if match == nil {
+		return nil
+	}
+	captures := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if name != "" && match[i] != nil {
+			captures[name] = string(match[i])
+		}
+	}
+	return captures
+}
+
+// Color colors each match of p in s. Matching ignores ANSI escape sequences
+// in s, and the colors that s had are restored after each match.
 func (p Pattern) Color(s []byte, color *Color) []byte {
 	re, err := p.get()
 	if err != nil {
-		fmt.Println(err)
 		return s
 	}
 
-	var parts [][]byte
-	var prevIndex int
-	for _, match := range re.FindAllIndex(s, -1) {
-		prev := s[prevIndex:match[0]]
-		if len(prev) > 0 {
-			parts = append(parts, prev)
+	line := NewLine(s)
+	var result []byte
+	var prev int
+	for _, match := range re.FindAllIndex(line.Plain, -1) {
+		if match[0] == match[1] {
+			continue
 		}
-		colored := []byte(color.Sprint(string(s[match[0]:match[1]])))
-		parts = append(parts, colored)
-		prevIndex = match[1]
+		start, end := line.span(match[0], match[1])
+		result = append(result, s[prev:start]...)
+		result = append(result, color.Sprint(string(line.Plain[match[0]:match[1]]))...)
+		result = append(result, line.style(end)...)
+		prev = end
+	}
+	return append(result, s[prev:]...)
+}
+
+// Replace replaces s with the expansion of template if p matches, ignoring
+// ANSI escape sequences in s. The replacement is colored with color, or
+// otherwise with the colors that s had where the match began.
+func (p Pattern) Replace(s []byte, template string, color *Color) ([]byte, bool) {
+	re, err := p.get()
+	if err != nil {
+		return s, false
+	}
+
+	line := NewLine(s)
+	match := re.FindIndex(line.Plain)
+	if match == nil {
+		return s, false
+	}
+	with := p.Expand(line.Plain, template)
+	if color != nil {
+		return []byte(color.Sprint(with)), true
 	}
-	final := s[prevIndex:]
-	if len(final) > 0 {
-		parts = append(parts, final)
+	start, _ := line.span(match[0], match[1])
+	style := line.style(start)
+	if len(style) == 0 {
+		return []byte(with), true
 	}
-	return bytes.Join(parts, []byte{})
+	return []byte(string(style) + with + "\x1b[0m"), true
 }
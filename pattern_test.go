@@ -0,0 +1,108 @@
+package mud
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCaptures(t *testing.T) {
+	tests := map[string]struct {
+		pattern  Pattern
+		line     string
+		captures map[string]string
+	}{
+		"named": {
+			pattern:  `<(?P<hp>[0-9]+)hp (?P<mana>[0-9]+)m>`,
+			line:     "<120hp 45m> ",
+			captures: map[string]string{"hp": "120", "mana": "45"},
+		},
+		"unnamed": {
+			pattern:  `<([0-9]+)hp>`,
+			line:     "<120hp>",
+			captures: map[string]string{},
+		},
+		"optional": {
+			pattern:  `<(?P<hp>[0-9]+)hp( (?P<fighting>F))?>`,
+			line:     "<120hp>",
+			captures: map[string]string{"hp": "120"},
+		},
+		"no match": {
+			pattern: `<(?P<hp>[0-9]+)hp>`,
+			line:    "You are hungry.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.pattern.Captures([]byte(test.line))
+			if diff := cmp.Diff(test.captures, got); diff != "" {
+				t.Errorf("captures mismatch: %v", diff)
+			}
+		})
+	}
+}
+
+func TestExpandRaw(t *testing.T) {
+	line := NewLine([]byte("\x1b[1mBob\x1b[0m says '\x1b[33mhi\x1b[0m'"))
+	tests := map[string]struct {
+		pattern  Pattern
+		template string
+		result   string
+	}{
+		"whole match": {pattern: `\w+ says '.*'`, template: "$0", result: "\x1b[1mBob\x1b[0m says '\x1b[33mhi\x1b[0m'"},
+		"group":       {pattern: `(\w+) says`, template: "<$1>", result: "<\x1b[1mBob>"},
+		"no match":    {pattern: `shouts`, template: "$0", result: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.pattern.ExpandRaw(line, test.template); got != test.result {
+				t.Errorf("got %q, want %q", got, test.result)
+			}
+		})
+	}
+}
+
+func TestPatternKinds(t *testing.T) {
+	tests := map[string]struct {
+		pattern Pattern
+		kind    string
+		line    string
+		match   bool
+		expand  string
+	}{
+		"regexp":           {pattern: `There were (\d+) coins.`, kind: "re", line: "There were 12 coins!", match: true},
+		"re prefix":        {pattern: `re:^You (\w+)`, kind: "re", line: "You hit", match: true, expand: "hit"},
+		"literal":          {pattern: `literal:There were (\d+) coins.`, kind: "literal", line: "There were 12 coins!"},
+		"literal match":    {pattern: `literal:coins.`, kind: "literal", line: "There were 12 coins.", match: true},
+		"glob":             {pattern: `glob:* tells you '*'`, kind: "glob", line: "Bob tells you 'hi there'", match: true, expand: "Bob hi there"},
+		"glob anchored":    {pattern: `glob:* tells you '*'`, kind: "glob", line: "Bob tells you 'hi' and leaves"},
+		"glob literal":     {pattern: `glob:You get (3) coins.`, kind: "glob", line: "You get (3) coins.", match: true},
+		"line":             {pattern: `line:You are (\w+)`, kind: "line", line: "You are hungry", match: true, expand: "hungry"},
+		"line anchored":    {pattern: `line:You are (\w+)`, kind: "line", line: "You are hungry."},
+		"line alternation": {pattern: `line:a|b`, kind: "line", line: "ab"},
+		"ire":              {pattern: `ire:you are (\w+)`, kind: "ire", line: "YOU ARE HUNGRY", match: true, expand: "HUNGRY"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if kind := test.pattern.Kind(); kind != test.kind {
+				t.Errorf("got kind %q, want %q", kind, test.kind)
+			}
+			if match := test.pattern.Match([]byte(test.line)); match != test.match {
+				t.Fatalf("Match(%q) = %v, want %v", test.line, match, test.match)
+			}
+			if test.expand == "" {
+				return
+			}
+			template := "$1"
+			if test.kind == "glob" {
+				template = "$1 $2"
+			}
+			if got := test.pattern.Expand([]byte(test.line), template); got != test.expand {
+				t.Errorf("got %q, want %q", got, test.expand)
+			}
+		})
+	}
+}
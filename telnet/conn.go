@@ -1,22 +1,52 @@
 package telnet
 
 import (
+	"bufio"
 	"bytes"
+	"compress/zlib"
+	"crypto/tls"
+	"io"
 	"net"
+	"regexp"
 	"sync"
+	"time"
 )
 
 // A Conn is a telnet connection.
 type Conn struct {
 	net.Conn
 
+	// r is where server data is read from: either raw, or a zlib stream
+	// layered on top of it while MCCP2 compression is active.
+	r   io.Reader
+	raw *bufio.Reader
+	zr  io.ReadCloser
 	buf []byte
 
+	cfg Config
+
 	processor *tnProcessor
 	// Event/msg (out-of-band) handlers
 	handlers     []*handlerRunner
 	handlerMutex sync.Mutex
 	// Some channels for command sequences?
+
+	// Telnet option state
+	opts optionTable
+
+	// GMCP message and MSDP variable routes
+	gmcp gmcpMux
+	msdp msdpMux
+
+	// Prompt detection state
+	line         []byte
+	promptMarked bool
+	chunks       chan chunk
+	pending      []byte
+	pendingErr   error
+	pumpOnce     sync.Once
+	done         chan struct{}
+	closeOnce    sync.Once
 }
 
 // AddHandler adds a new out-of-band msg handler that will be invoked for
@@ -39,43 +69,201 @@ func (t *Conn) AddHandler(h Handler) {
 	t.handlers = append(t.handlers, runner)
 }
 
+// Config holds the settings a Conn uses when negotiating with the server.
+// The zero value is a usable default.
+type Config struct {
+	// Client and Version identify the client, in the GMCP Core.Hello
+	// message and the first TTYPE response. If Client is empty, the client
+	// is "gmudc" version 0.0.2.
+	Client  string
+	Version string
+
+	// GMCPSupports lists the GMCP modules to request with Core.Supports.Set,
+	// e.g. "Char 1" or "Room 1". If nil, DefaultGMCPSupports is used.
+	GMCPSupports []string
+
+	// MSDPReport lists the MSDP variables to REPORT once the server enables
+	// MSDP, e.g. "HEALTH" or "ROOM".
+	MSDPReport []string
+
+	// Width and Height are the window size reported with NAWS, 80x24 by
+	// default. It can be changed later with SetWindowSize.
+	Width, Height int
+
+	// TerminalType and MTTS are reported with TTYPE. The defaults are "ANSI"
+	// and DefaultMTTS.
+	TerminalType string
+	MTTS         MTTS
+
+	// TLS, if not nil, is used to make a TLS connection to the server.
+	TLS *tls.Config
+
+	// Prompt selects how prompts, which usually don't end with a newline,
+	// are recognized. PromptTimeout is how long to wait for the rest of a
+	// line before taking it to be a prompt, 250ms by default, and
+	// PromptPattern is the pattern matched by PromptRegexp.
+	Prompt        PromptMode
+	PromptTimeout time.Duration
+	PromptPattern *regexp.Regexp
+}
+
+// DefaultGMCPSupports is the list of GMCP modules requested when
+// Config.GMCPSupports is nil.
+var DefaultGMCPSupports = []string{"Char 1", "Char.Skills 1", "Char.Items 1", "Comm.Channel 1", "Room 1"}
+
 // Dial will attempt to make a Conn to the type/address specific
 // eg: conn.Dial("tcp", "somewhere.com:23")
 func Dial(network string, url string) (*Conn, error) {
+	return DialConfig(network, url, nil)
+}
+
+// DialConfig is like Dial, but negotiates with the server according to cfg.
+// A nil cfg is the same as the zero Config.
+func DialConfig(network string, url string, cfg *Config) (*Conn, error) {
+	var c net.Conn
+	var err error
+	if cfg != nil && cfg.TLS != nil {
+		c, err = tls.Dial(network, url, cfg.TLS)
+	} else {
+		c, err = net.Dial(network, url)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newConn(c, cfg), nil
+}
+
+func newConn(c net.Conn, cfg *Config) *Conn {
 	tc := &Conn{
+		Conn:      c,
+		raw:       bufio.NewReader(c),
 		processor: newTelnetProcessor(),
 		handlers:  make([]*handlerRunner, 0),
+		done:      make(chan struct{}),
 	}
-	tc.processor.conn = tc
-
-	c, err := net.Dial(network, url)
-	if err != nil {
-		return nil, err
+	if cfg != nil {
+		tc.cfg = *cfg
+	}
+	if tc.cfg.Client == "" {
+		tc.cfg.Client, tc.cfg.Version = "gmudc", "0.0.2"
+	}
+	tc.gmcp.supports = tc.cfg.GMCPSupports
+	if tc.gmcp.supports == nil {
+		tc.gmcp.supports = DefaultGMCPSupports
 	}
-	tc.Conn = c
+	if tc.cfg.PromptTimeout <= 0 {
+		tc.cfg.PromptTimeout = DefaultPromptTimeout
+	}
+	tc.initOptions()
+	tc.r = tc.raw
+	tc.processor.conn = tc
 
 	startSystemHandlers(tc)
-	return tc, nil
+	return tc
+}
+
+// Close closes the connection.
+func (t *Conn) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.Conn.Close()
 }
 
-// Conn implements the io.Reader interface.
+// Conn implements the io.Reader interface. The end of each prompt is marked
+// with \x04 (EOT), as configured by Config.Prompt.
 func (t *Conn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if t.cfg.Prompt == PromptTimeout {
+		return t.readTimeout(b)
+	}
+	return t.read(b)
+}
+
+// read returns the next clean data from the server, with prompts marked as
+// they are recognized by GA/EOR or Config.PromptPattern.
+func (t *Conn) read(b []byte) (int, error) {
+	if t.promptMarked {
+		t.promptMarked = false
+		b[0] = '\x04'
+		return 1, nil
+	}
+
+	// Keep reading until there is something other than telnet commands to
+	// return; a chunk of (possibly compressed) data may contain nothing else.
+	for len(t.processor.cleanBytes) == 0 {
+		if err := t.fill(); err != nil {
+			if len(t.processor.cleanBytes) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+
+	n, err := t.processor.Read(b)
+	t.trackLine(b[:n])
+	if t.cfg.Prompt == PromptRegexp && t.atPrompt() {
+		// the marker is returned by the next Read
+		t.promptMarked = true
+		t.line = t.line[:0]
+	}
+	return n, err
+}
+
+// fill reads the next chunk of data from the server and runs it through the
+// telnet processor, switching to or from MCCP2 decompression as the stream
+// requires.
+func (t *Conn) fill() error {
 	if t.buf == nil {
 		t.buf = make([]byte, 1024)
 	}
-	n, err := t.Conn.Read(t.buf)
-	t.processor.processBytes(t.buf[:n])
-	if err != nil {
-		return n, err
+	n, err := t.r.Read(t.buf)
+
+	data := t.buf[:n]
+	for len(data) > 0 {
+		data = data[t.processor.processBytes(data):]
+		if t.processor.compressing && t.zr == nil {
+			if err := t.startCompression(data); err != nil {
+				return err
+			}
+			data = nil
+		}
 	}
 
-	n, err = t.processor.Read(b)
-	if n < len(b) && err == nil && b[n-1] != '\x04' {
-		// waiting at a prompt?
-		b[n] = '\x04' // EOT
-		return n + 1, nil
+	if err == io.EOF && t.zr != nil {
+		// The server ended the compressed stream; carry on with plain
+		// telnet.
+		t.stopCompression()
+		return nil
 	}
-	return n, err
+	return err
+}
+
+// startCompression layers a zlib stream on top of the connection. rest holds
+// any bytes that were already read past the start of compression.
+func (t *Conn) startCompression(rest []byte) error {
+	if len(rest) > 0 {
+		pending := append([]byte(nil), rest...)
+		t.raw = bufio.NewReader(io.MultiReader(bytes.NewReader(pending), t.raw))
+	}
+
+	// zlib reads through t.raw one byte at a time (bufio.Reader is an
+	// io.ByteReader), so nothing past the end of the compressed stream is
+	// consumed.
+	zr, err := zlib.NewReader(t.raw)
+	if err != nil {
+		return err
+	}
+	t.zr = zr
+	t.r = zr
+	return nil
+}
+
+func (t *Conn) stopCompression() {
+	t.zr.Close()
+	t.zr = nil
+	t.r = t.raw
+	t.processor.compressing = false
 }
 
 // SendCommand formats and sends a command (series of tnSeq) to the server.
@@ -0,0 +1,233 @@
+package telnet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := newConn(client, nil)
+
+	errc := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		if _, err := server.Write([]byte{byte(IAC), byte(WILL), byte(CMP2)}); err != nil {
+			errc <- err
+			return
+		}
+		reply := make([]byte, 3)
+		if _, err := io.ReadFull(server, reply); err != nil {
+			errc <- err
+			return
+		}
+		if want := []byte{byte(IAC), byte(DO), byte(CMP2)}; !bytes.Equal(reply, want) {
+			t.Errorf("got reply %v, want %v", reply, want)
+		}
+
+		var msg bytes.Buffer
+		msg.WriteString("plain\n")
+		msg.Write([]byte{byte(IAC), byte(SB), byte(CMP2), byte(IAC), byte(SE)})
+		zw := zlib.NewWriter(&msg)
+		zw.Write([]byte("compressed\n> "))
+		zw.Write([]byte{byte(IAC), byte(GA)})
+		zw.Close()
+		msg.WriteString("plain again\n")
+
+		_, err := server.Write(msg.Bytes())
+		errc <- err
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexAny(data, "\x04\n"); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	})
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"plain", "compressed", "> ", "plain again"}
+	if len(lines) != len(want) {
+		t.Fatalf("got lines %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	// sent by the server in separate writes, with a pause between them
+	type step struct {
+		data  string
+		pause time.Duration
+	}
+	iac := func(seq ...tnSeq) string { return string(buildCommand(seq...)) }
+
+	tests := map[string]struct {
+		cfg    Config
+		steps  []step
+		result string
+	}{
+		"ga": {
+			steps:  []step{{data: "hello\n> " + iac(GA)}, {data: "ok\n"}},
+			result: "hello\n> \x04ok\n",
+		},
+		"eor": {
+			steps:  []step{{data: iac(WILL, EOR)}, {data: "> " + iac(EORC)}, {data: "ok\n"}},
+			result: "> \x04ok\n",
+		},
+		"eor not enabled": {
+			steps:  []step{{data: "> " + iac(EORC)}, {data: "ok\n"}},
+			result: "> ok\n",
+		},
+		"no guessing": {
+			steps:  []step{{data: "> ", pause: 100 * time.Millisecond}, {data: "ok\n"}},
+			result: "> ok\n",
+		},
+		"timeout": {
+			cfg:    Config{Prompt: PromptTimeout, PromptTimeout: 20 * time.Millisecond},
+			steps:  []step{{data: "> ", pause: 200 * time.Millisecond}, {data: "ok\n"}},
+			result: "> \x04ok\n",
+		},
+		"timeout partial": {
+			cfg:    Config{Prompt: PromptTimeout, PromptTimeout: time.Second},
+			steps:  []step{{data: "par"}, {data: "tial\n"}},
+			result: "partial\n",
+		},
+		"regexp": {
+			cfg:    Config{Prompt: PromptRegexp, PromptPattern: regexp.MustCompile(`^<[0-9]+hp> $`)},
+			steps:  []step{{data: "<12"}, {data: "0hp> "}, {data: "ok\n<"}, {data: "hp> \n"}, {data: "\x1b[32m<5hp>\x1b[0m "}},
+			result: "<120hp> \x04ok\n<hp> \n\x1b[32m<5hp>\x1b[0m \x04",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			cfg := test.cfg
+			conn := newConn(client, &cfg)
+			defer conn.Close()
+
+			// discard negotiation replies
+			go io.Copy(ioutil.Discard, server)
+			go func() {
+				defer server.Close()
+				for _, s := range test.steps {
+					if _, err := server.Write([]byte(s.data)); err != nil {
+						return
+					}
+					time.Sleep(s.pause)
+				}
+			}()
+
+			got, err := ioutil.ReadAll(conn)
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if string(got) != test.result {
+				t.Errorf("got %q, want %q", got, test.result)
+			}
+		})
+	}
+}
+
+func TestTLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Write([]byte("hello\n"))
+			c.Close()
+		}
+	}()
+
+	tests := map[string]struct {
+		cfg *tls.Config
+		err bool
+	}{
+		"trusted":    {cfg: &tls.Config{RootCAs: pool}},
+		"untrusted":  {cfg: &tls.Config{}, err: true},
+		"insecure":   {cfg: &tls.Config{InsecureSkipVerify: true}},
+		"wrong host": {cfg: &tls.Config{RootCAs: pool, ServerName: "example.com"}, err: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn, err := DialConfig("tcp", ln.Addr().String(), &Config{TLS: test.cfg})
+			if err == nil {
+				defer conn.Close()
+				// the handshake happens on first use
+				_, err = bufio.NewReader(conn).ReadString('\n')
+			}
+			if err != nil != test.err {
+				t.Errorf("error mismatch: got %v, want error: %v", err, test.err)
+			}
+		})
+	}
+}
+
+// testCertificate generates a self-signed certificate for 127.0.0.1, and a
+// pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
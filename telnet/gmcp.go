@@ -0,0 +1,226 @@
+package telnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// A GMCPMessage is a GMCP message received from the server.
+type GMCPMessage struct {
+	// Package is the full package and message name, e.g. "Char.Vitals".
+	Package string
+
+	// Data is the decoded JSON payload, or nil if there was no payload or it
+	// could not be decoded.
+	Data interface{}
+
+	// Raw is the payload as sent by the server.
+	Raw []byte
+}
+
+// GMCPHandler is the interface that needs to be implemented for anything that
+// wishes to act upon IAC SB GMCP messages.
+type GMCPHandler interface {
+	HandleGMCP(*GMCPMessage)
+}
+
+// The GMCPHandlerFunc type is an adapter to allow the use of ordinary
+// functions as GMCP handlers.
+type GMCPHandlerFunc func(*GMCPMessage)
+
+// HandleGMCP calls f(msg).
+func (f GMCPHandlerFunc) HandleGMCP(msg *GMCPMessage) {
+	f(msg)
+}
+
+type gmcpRoute struct {
+	prefix  string
+	handler GMCPHandler
+}
+
+type gmcpMux struct {
+	sync.RWMutex
+	routes []gmcpRoute
+
+	// supports is the current list of modules requested from the server.
+	supports []string
+	enabled  bool
+}
+
+// HandleGMCP registers h to receive GMCP messages in the package pkg. Package
+// names match by prefix on whole components, ignoring case: "Char" receives
+// "Char.Vitals" and "Char.Status", but not "Character". An empty pkg
+// receives every message.
+//
+// Handlers are called one at a time, in the order messages arrive, from a
+// single goroutine. Like other handlers, they should return quickly.
+func (t *Conn) HandleGMCP(pkg string, h GMCPHandler) {
+	t.gmcp.Lock()
+	defer t.gmcp.Unlock()
+	t.gmcp.routes = append(t.gmcp.routes, gmcpRoute{prefix: pkg, handler: h})
+}
+
+// HandleGMCPFunc registers the handler function f for GMCP messages in the
+// package pkg.
+func (t *Conn) HandleGMCPFunc(pkg string, f func(*GMCPMessage)) {
+	t.HandleGMCP(pkg, GMCPHandlerFunc(f))
+}
+
+// SendGMCP sends a GMCP message to the server. Unless v is nil, it is encoded
+// as JSON and sent as the message payload.
+// eg: conn.SendGMCP("Core.Supports.Add", []string{"Room 1"})
+func (t *Conn) SendGMCP(pkg string, v interface{}) error {
+	msg := []byte(pkg)
+	if v != nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		msg = append(msg, ' ')
+		msg = append(msg, data...)
+	}
+	return t.sendSub(GMCP, msg)
+}
+
+// GMCPSubscribe asks the server to start sending the given GMCP modules,
+// e.g. "Room 1", with Core.Supports.Add. If GMCP has not been enabled yet,
+// the modules are requested once it is.
+func (t *Conn) GMCPSubscribe(modules ...string) error {
+	t.gmcp.Lock()
+	supports := make([]string, 0, len(t.gmcp.supports)+len(modules))
+	for _, s := range t.gmcp.supports {
+		if !containsModule(modules, s) {
+			supports = append(supports, s)
+		}
+	}
+	t.gmcp.supports = append(supports, modules...)
+	enabled := t.gmcp.enabled
+	t.gmcp.Unlock()
+
+	if !enabled {
+		return nil
+	}
+	return t.SendGMCP("Core.Supports.Add", modules)
+}
+
+// GMCPUnsubscribe asks the server to stop sending the given GMCP modules
+// with Core.Supports.Remove.
+func (t *Conn) GMCPUnsubscribe(modules ...string) error {
+	t.gmcp.Lock()
+	var supports []string
+	for _, s := range t.gmcp.supports {
+		if !containsModule(modules, s) {
+			supports = append(supports, s)
+		}
+	}
+	t.gmcp.supports = supports
+	enabled := t.gmcp.enabled
+	t.gmcp.Unlock()
+
+	if !enabled {
+		return nil
+	}
+	names := make([]string, len(modules))
+	for i, m := range modules {
+		names[i] = moduleName(m)
+	}
+	return t.SendGMCP("Core.Supports.Remove", names)
+}
+
+// GMCPSupports returns the list of GMCP modules currently requested from the
+// server.
+func (t *Conn) GMCPSupports() []string {
+	t.gmcp.RLock()
+	defer t.gmcp.RUnlock()
+	return append([]string(nil), t.gmcp.supports...)
+}
+
+// gmcpHello introduces the client once the server has agreed to speak GMCP.
+func (t *Conn) gmcpHello() {
+	t.gmcp.Lock()
+	t.gmcp.enabled = true
+	supports := append([]string(nil), t.gmcp.supports...)
+	t.gmcp.Unlock()
+
+	hello := map[string]string{"client": t.cfg.Client}
+	if t.cfg.Version != "" {
+		hello["version"] = t.cfg.Version
+	}
+	t.SendGMCP("Core.Hello", hello)
+	t.SendGMCP("Core.Supports.Set", supports)
+}
+
+// moduleName strips the version from a module, e.g. "Room 1" -> "Room".
+func moduleName(module string) string {
+	if i := strings.IndexByte(module, ' '); i >= 0 {
+		return module[:i]
+	}
+	return module
+}
+
+func containsModule(modules []string, module string) bool {
+	for _, m := range modules {
+		if strings.EqualFold(moduleName(m), moduleName(module)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Conn) dispatchGMCP(msg *GMCPMessage) {
+	t.gmcp.RLock()
+	routes := t.gmcp.routes
+	t.gmcp.RUnlock()
+
+	for _, r := range routes {
+		if MatchGMCP(r.prefix, msg.Package) {
+			r.handler.HandleGMCP(msg)
+		}
+	}
+}
+
+// MatchGMCP reports whether the GMCP package pkg is matched by prefix, in
+// the same way as for HandleGMCP.
+func MatchGMCP(prefix, pkg string) bool {
+	if prefix == "" {
+		return true
+	}
+	if len(pkg) < len(prefix) || !strings.EqualFold(pkg[:len(prefix)], prefix) {
+		return false
+	}
+	return len(pkg) == len(prefix) || pkg[len(prefix)] == '.'
+}
+
+type gmcpInboundHandler struct {
+	conn *Conn
+}
+
+func (h *gmcpInboundHandler) Handle(msg []byte) {
+	if !hasSeqPrefix(msg, GMCP) {
+		return
+	}
+	msg = msg[1:]
+
+	var module, data []byte
+	si := bytes.IndexByte(msg, ' ')
+	if si == -1 {
+		module = msg
+	} else {
+		module = msg[:si]
+		data = bytes.TrimSpace(msg[si+1:])
+	}
+
+	gmsg := &GMCPMessage{
+		Package: string(module),
+		Raw:     data,
+	}
+	if len(data) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err == nil {
+			gmsg.Data = v
+		}
+	}
+	h.conn.dispatchGMCP(gmsg)
+}
@@ -0,0 +1,69 @@
+package telnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchGMCP(t *testing.T) {
+	tests := map[string]struct {
+		prefix string
+		pkg    string
+		match  bool
+	}{
+		"everything":     {prefix: "", pkg: "Char.Vitals", match: true},
+		"exact":          {prefix: "Char.Vitals", pkg: "Char.Vitals", match: true},
+		"prefix":         {prefix: "Char", pkg: "Char.Vitals", match: true},
+		"case":           {prefix: "char.vitals", pkg: "Char.Vitals", match: true},
+		"partial word":   {prefix: "Char", pkg: "Character.Vitals", match: false},
+		"longer":         {prefix: "Char.Vitals", pkg: "Char", match: false},
+		"other":          {prefix: "Room", pkg: "Char.Vitals", match: false},
+		"nested package": {prefix: "Comm.Channel", pkg: "Comm.Channel.Text", match: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := MatchGMCP(test.prefix, test.pkg); got != test.match {
+				t.Errorf("MatchGMCP(%q, %q) = %v, want %v", test.prefix, test.pkg, got, test.match)
+			}
+		})
+	}
+}
+
+func TestGMCPDispatch(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	conn := newConn(client, nil)
+
+	msgs := make(chan *GMCPMessage, 1)
+	conn.HandleGMCPFunc("Char", func(msg *GMCPMessage) {
+		msgs <- msg
+	})
+
+	var data []byte
+	data = append(data, byte(IAC), byte(SB), byte(GMCP))
+	data = append(data, `Room.Info {"num": 1}`...)
+	data = append(data, byte(IAC), byte(SE))
+	data = append(data, byte(IAC), byte(SB), byte(GMCP))
+	data = append(data, `Char.Vitals {"hp": 100, "maxhp": 120}`...)
+	data = append(data, byte(IAC), byte(SE))
+	conn.processor.processBytes(data)
+
+	select {
+	case msg := <-msgs:
+		want := &GMCPMessage{
+			Package: "Char.Vitals",
+			Data:    map[string]interface{}{"hp": 100.0, "maxhp": 120.0},
+			Raw:     []byte(`{"hp": 100, "maxhp": 120}`),
+		}
+		if diff := cmp.Diff(want, msg); diff != "" {
+			t.Errorf("message mismatch: %v", diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+}
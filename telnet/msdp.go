@@ -0,0 +1,236 @@
+package telnet
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// MSDP subnegotiation control bytes.
+const (
+	msdpVar        = 1
+	msdpVal        = 2
+	msdpTableOpen  = 3
+	msdpTableClose = 4
+	msdpArrayOpen  = 5
+	msdpArrayClose = 6
+)
+
+// MSDPHandler is the interface that needs to be implemented for anything that
+// wishes to act upon MSDP variables sent by the server.
+//
+// Values are decoded into strings, tables into map[string]interface{} and
+// arrays into []interface{}, as with encoding/json.
+type MSDPHandler interface {
+	HandleMSDP(name string, value interface{})
+}
+
+// The MSDPHandlerFunc type is an adapter to allow the use of ordinary
+// functions as MSDP handlers.
+type MSDPHandlerFunc func(name string, value interface{})
+
+// HandleMSDP calls f(name, value).
+func (f MSDPHandlerFunc) HandleMSDP(name string, value interface{}) {
+	f(name, value)
+}
+
+type msdpRoute struct {
+	name    string
+	handler MSDPHandler
+}
+
+type msdpMux struct {
+	sync.RWMutex
+	routes []msdpRoute
+}
+
+// HandleMSDP registers h to receive the MSDP variable name, e.g. "HEALTH".
+// An empty name receives every variable. As with GMCP handlers, h is called
+// from a single goroutine and should return quickly.
+func (t *Conn) HandleMSDP(name string, h MSDPHandler) {
+	t.msdp.Lock()
+	defer t.msdp.Unlock()
+	t.msdp.routes = append(t.msdp.routes, msdpRoute{name: name, handler: h})
+}
+
+// HandleMSDPFunc registers the handler function f for the MSDP variable name.
+func (t *Conn) HandleMSDPFunc(name string, f func(string, interface{})) {
+	t.HandleMSDP(name, MSDPHandlerFunc(f))
+}
+
+// SendMSDP sends the MSDP variable name to the server, with the given values.
+// eg: conn.SendMSDP("REPORT", "HEALTH", "MANA")
+func (t *Conn) SendMSDP(name string, values ...string) error {
+	var msg bytes.Buffer
+	msg.WriteByte(msdpVar)
+	msg.WriteString(name)
+	for _, v := range values {
+		msg.WriteByte(msdpVal)
+		msg.WriteString(v)
+	}
+	return t.sendSub(MSDP, msg.Bytes())
+}
+
+// MSDPReport asks the server to send the given variables whenever they
+// change.
+func (t *Conn) MSDPReport(names ...string) error {
+	return t.SendMSDP("REPORT", names...)
+}
+
+// MSDPUnreport asks the server to stop reporting the given variables.
+func (t *Conn) MSDPUnreport(names ...string) error {
+	return t.SendMSDP("UNREPORT", names...)
+}
+
+// MSDPSend asks the server to send the current values of the given
+// variables once.
+func (t *Conn) MSDPSend(names ...string) error {
+	return t.SendMSDP("SEND", names...)
+}
+
+// msdpHello requests the configured variables once the server has agreed to
+// speak MSDP.
+func (t *Conn) msdpHello() {
+	if len(t.cfg.MSDPReport) > 0 {
+		t.MSDPReport(t.cfg.MSDPReport...)
+	}
+}
+
+func (t *Conn) dispatchMSDP(name string, value interface{}) {
+	t.msdp.RLock()
+	routes := t.msdp.routes
+	t.msdp.RUnlock()
+
+	for _, r := range routes {
+		if r.name == "" || r.name == name {
+			r.handler.HandleMSDP(name, value)
+		}
+	}
+}
+
+type msdpInboundHandler struct {
+	conn *Conn
+}
+
+func (h *msdpInboundHandler) Handle(msg []byte) {
+	if !hasSeqPrefix(msg, MSDP) {
+		return
+	}
+	vars, err := parseMSDP(msg[1:])
+	if err != nil {
+		return
+	}
+	for _, v := range vars {
+		h.conn.dispatchMSDP(v.Name, v.Value)
+	}
+}
+
+type msdpVariable struct {
+	Name  string
+	Value interface{}
+}
+
+// parseMSDP decodes the variables in an MSDP subnegotiation, in the order
+// they were sent.
+func parseMSDP(b []byte) ([]msdpVariable, error) {
+	p := &msdpParser{buf: b}
+	var vars []msdpVariable
+	for p.pos < len(p.buf) {
+		name, value, err := p.variable()
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, msdpVariable{Name: name, Value: value})
+	}
+	return vars, nil
+}
+
+type msdpParser struct {
+	buf []byte
+	pos int
+}
+
+// variable parses VAR name VAL value [VAL value ...]. A variable with more
+// than one value is decoded as an array.
+func (p *msdpParser) variable() (string, interface{}, error) {
+	if p.buf[p.pos] != msdpVar {
+		return "", nil, fmt.Errorf("msdp: expected VAR at offset %d", p.pos)
+	}
+	p.pos++
+	name := p.text()
+
+	var values []interface{}
+	for p.pos < len(p.buf) && p.buf[p.pos] == msdpVal {
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return "", nil, err
+		}
+		values = append(values, v)
+	}
+
+	switch len(values) {
+	case 0:
+		return name, "", nil
+	case 1:
+		return name, values[0], nil
+	default:
+		return name, values, nil
+	}
+}
+
+func (p *msdpParser) value() (interface{}, error) {
+	if p.pos >= len(p.buf) {
+		return "", nil
+	}
+
+	switch p.buf[p.pos] {
+	case msdpTableOpen:
+		p.pos++
+		table := make(map[string]interface{})
+		for p.pos < len(p.buf) && p.buf[p.pos] != msdpTableClose {
+			name, value, err := p.variable()
+			if err != nil {
+				return nil, err
+			}
+			table[name] = value
+		}
+		if p.pos >= len(p.buf) {
+			return nil, fmt.Errorf("msdp: unterminated table")
+		}
+		p.pos++
+		return table, nil
+
+	case msdpArrayOpen:
+		p.pos++
+		array := make([]interface{}, 0)
+		for p.pos < len(p.buf) && p.buf[p.pos] != msdpArrayClose {
+			if p.buf[p.pos] != msdpVal {
+				return nil, fmt.Errorf("msdp: expected VAL at offset %d", p.pos)
+			}
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+		if p.pos >= len(p.buf) {
+			return nil, fmt.Errorf("msdp: unterminated array")
+		}
+		p.pos++
+		return array, nil
+
+	default:
+		return p.text(), nil
+	}
+}
+
+// text scans up to the next control byte.
+func (p *msdpParser) text() string {
+	start := p.pos
+	for p.pos < len(p.buf) && p.buf[p.pos] > msdpArrayClose {
+		p.pos++
+	}
+	return string(p.buf[start:p.pos])
+}
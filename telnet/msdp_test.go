@@ -0,0 +1,75 @@
+package telnet
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMSDP(t *testing.T) {
+	const (
+		V  = "\x01"
+		L  = "\x02"
+		TO = "\x03"
+		TC = "\x04"
+		AO = "\x05"
+		AC = "\x06"
+	)
+
+	tests := map[string]struct {
+		input  string
+		result []msdpVariable
+		err    bool
+	}{
+		"value": {
+			input:  V + "HEALTH" + L + "95",
+			result: []msdpVariable{{Name: "HEALTH", Value: "95"}},
+		},
+		"several variables": {
+			input: V + "HEALTH" + L + "95" + V + "MANA" + L + "40",
+			result: []msdpVariable{
+				{Name: "HEALTH", Value: "95"},
+				{Name: "MANA", Value: "40"},
+			},
+		},
+		"empty": {
+			input:  V + "TARGET" + L,
+			result: []msdpVariable{{Name: "TARGET", Value: ""}},
+		},
+		"array": {
+			input:  V + "REPORTABLE_VARIABLES" + L + AO + L + "HEALTH" + L + "MANA" + AC,
+			result: []msdpVariable{{Name: "REPORTABLE_VARIABLES", Value: []interface{}{"HEALTH", "MANA"}}},
+		},
+		"repeated values": {
+			input:  V + "LIST" + L + "a" + L + "b",
+			result: []msdpVariable{{Name: "LIST", Value: []interface{}{"a", "b"}}},
+		},
+		"table": {
+			input: V + "ROOM" + L + TO + V + "VNUM" + L + "6008" + V + "EXITS" + L + TO + V + "n" + L + "6011" + TC + TC,
+			result: []msdpVariable{{Name: "ROOM", Value: map[string]interface{}{
+				"VNUM":  "6008",
+				"EXITS": map[string]interface{}{"n": "6011"},
+			}}},
+		},
+		"unterminated table": {
+			input: V + "ROOM" + L + TO + V + "VNUM" + L + "6008",
+			err:   true,
+		},
+		"missing var": {
+			input: L + "95",
+			err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseMSDP([]byte(test.input))
+			if err != nil != test.err {
+				t.Errorf("error mismatch: got (err != nil) == %v, want %v", err != nil, test.err)
+			}
+			if diff := cmp.Diff(test.result, got); diff != "" {
+				t.Errorf("result mismatch: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package telnet
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TTYPE subnegotiation commands.
+const (
+	ttypeIS   = 0
+	ttypeSEND = 1
+)
+
+// MTTS is a set of terminal capabilities, reported to the server during
+// TTYPE negotiation as defined by the Mud Terminal Type Standard.
+type MTTS int
+
+const (
+	MTTSANSI MTTS = 1 << iota
+	MTTSVT100
+	MTTSUTF8
+	MTTS256Colors
+	MTTSMouseTracking
+	MTTSOSCColorPalette
+	MTTSScreenReader
+	MTTSProxy
+	MTTSTrueColor
+	MTTSMNES
+	MTTSMSLP
+	MTTSSSL
+)
+
+// DefaultMTTS is the set of capabilities reported when Config.MTTS is zero.
+const DefaultMTTS = MTTSANSI | MTTSVT100 | MTTSUTF8 | MTTS256Colors
+
+// qState is the state of one side of an option, as in the "Q method" of
+// RFC 1143.
+type qState int
+
+const (
+	qNo qState = iota
+	qYes
+	qWantNo
+	qWantYes
+)
+
+// An option describes how the client handles a telnet option.
+type option struct {
+	// local is set if we agree to perform the option when the server asks
+	// with DO, and remote if we agree to let the server perform it when it
+	// offers with WILL.
+	local, remote bool
+
+	// The state of the option on each side, and whether a request for the
+	// opposite state is queued behind the one in progress.
+	us, him   qState
+	usq, himq bool
+
+	// enabled, if set, is called when the option comes into effect on the
+	// side(s) we support it on.
+	enabled func()
+	// sub, if set, is called with the data of subnegotiations for the
+	// option.
+	sub func(data []byte)
+}
+
+// An OptionHook is called whenever an option is enabled or disabled, either
+// on the server's side (remote) or ours. Hooks are called from the goroutine
+// reading from the connection, and must not block.
+type OptionHook func(opt tnSeq, remote, enabled bool)
+
+type optionTable struct {
+	sync.Mutex
+	options map[tnSeq]*option
+	hooks   []OptionHook
+
+	width, height int
+	ttypes        []string
+	ttype         int
+}
+
+// get returns the state of opt, creating it if necessary. The caller must
+// hold the lock.
+func (ot *optionTable) get(opt tnSeq) *option {
+	o, ok := ot.options[opt]
+	if !ok {
+		o = &option{}
+		ot.options[opt] = o
+	}
+	return o
+}
+
+func (t *Conn) initOptions() {
+	t.opts.options = map[tnSeq]*option{
+		// Compression is handled transparently by Conn.Read.
+		CMP2: {remote: true},
+		// The server marks prompts with IAC EOR, like GA.
+		EOR:  {remote: true},
+		GMCP: {remote: true, enabled: t.gmcpHello},
+		MSDP: {remote: true, enabled: t.msdpHello},
+		WS:   {local: true, enabled: func() { t.sendWindowSize() }},
+		TT:   {local: true, sub: t.sendTerminalType},
+	}
+
+	t.opts.width, t.opts.height = t.cfg.Width, t.cfg.Height
+	if t.opts.width <= 0 || t.opts.height <= 0 {
+		t.opts.width, t.opts.height = 80, 24
+	}
+
+	// TTYPE cycles through the client name, the terminal type and the MTTS
+	// bitvector, then keeps repeating the last.
+	ttype, mtts := t.cfg.TerminalType, t.cfg.MTTS
+	if ttype == "" {
+		ttype = "ANSI"
+	}
+	if mtts == 0 {
+		mtts = DefaultMTTS
+	}
+	t.opts.ttypes = []string{strings.ToUpper(t.cfg.Client), ttype, fmt.Sprintf("MTTS %d", mtts)}
+}
+
+// SupportLocal declares whether the client agrees to perform opt when the
+// server asks with DO. Requests for options that are not supported are
+// refused with WONT.
+func (t *Conn) SupportLocal(opt tnSeq, ok bool) {
+	t.opts.Lock()
+	defer t.opts.Unlock()
+	t.opts.get(opt).local = ok
+}
+
+// SupportRemote declares whether the client agrees to let the server perform
+// opt when it offers with WILL. Offers of options that are not supported are
+// refused with DONT.
+func (t *Conn) SupportRemote(opt tnSeq, ok bool) {
+	t.opts.Lock()
+	defer t.opts.Unlock()
+	t.opts.get(opt).remote = ok
+}
+
+// OnOption adds a hook to be called whenever an option is enabled or
+// disabled.
+func (t *Conn) OnOption(h OptionHook) {
+	t.opts.Lock()
+	defer t.opts.Unlock()
+	t.opts.hooks = append(t.opts.hooks, h)
+}
+
+// LocalEnabled reports whether opt is in effect on the client's side.
+func (t *Conn) LocalEnabled(opt tnSeq) bool {
+	t.opts.Lock()
+	defer t.opts.Unlock()
+	return t.opts.get(opt).us == qYes
+}
+
+// RemoteEnabled reports whether opt is in effect on the server's side.
+func (t *Conn) RemoteEnabled(opt tnSeq) bool {
+	t.opts.Lock()
+	defer t.opts.Unlock()
+	return t.opts.get(opt).him == qYes
+}
+
+// EnableLocal offers to perform opt, with WILL. It also marks opt as
+// supported locally.
+func (t *Conn) EnableLocal(opt tnSeq) {
+	t.request(opt, false, true)
+}
+
+// DisableLocal stops performing opt, with WONT.
+func (t *Conn) DisableLocal(opt tnSeq) {
+	t.request(opt, false, false)
+}
+
+// EnableRemote asks the server to perform opt, with DO. It also marks opt
+// as supported remotely.
+func (t *Conn) EnableRemote(opt tnSeq) {
+	t.request(opt, true, true)
+}
+
+// DisableRemote asks the server to stop performing opt, with DONT.
+func (t *Conn) DisableRemote(opt tnSeq) {
+	t.request(opt, true, false)
+}
+
+// request asks for opt to be enabled or disabled on one side. Requests that
+// are already satisfied or in progress are ignored.
+func (t *Conn) request(opt tnSeq, remote, enable bool) {
+	t.opts.Lock()
+	o := t.opts.get(opt)
+	state, queued := &o.us, &o.usq
+	yes, no := WILL, WONT
+	if remote {
+		state, queued = &o.him, &o.himq
+		yes, no = DO, DONT
+	}
+	if enable {
+		if remote {
+			o.remote = true
+		} else {
+			o.local = true
+		}
+	}
+	was := *state == qYes
+
+	var reply tnSeq
+	switch *state {
+	case qNo:
+		if enable {
+			*state, reply = qWantYes, yes
+		}
+	case qYes:
+		if !enable {
+			*state, reply = qWantNo, no
+		}
+	case qWantNo:
+		*queued = enable
+	case qWantYes:
+		*queued = !enable
+	}
+	hooks := t.opts.changed(opt, o, remote, was)
+	t.opts.Unlock()
+
+	if reply != 0 {
+		t.SendCommand(reply, opt)
+	}
+	hooks()
+}
+
+// negotiate answers a DO, DONT, WILL or WONT from the server, following the
+// "Q method" of RFC 1143, so that negotiation cannot loop and every request
+// for an option we don't support is refused.
+func (t *Conn) negotiate(verb, opt tnSeq) {
+	t.opts.Lock()
+	o := t.opts.get(opt)
+
+	remote := verb == WILL || verb == WONT
+	state, queued, supported := &o.us, &o.usq, o.local
+	yes, no := WILL, WONT
+	if remote {
+		state, queued, supported = &o.him, &o.himq, o.remote
+		yes, no = DO, DONT
+	}
+	was := *state == qYes
+
+	var reply tnSeq
+	if verb == WILL || verb == DO {
+		switch *state {
+		case qNo:
+			if supported {
+				*state, reply = qYes, yes
+			} else {
+				reply = no
+			}
+		case qWantNo:
+			// Our disable request was answered with an enable. If we had
+			// changed our mind in the meantime, accept it.
+			if *queued {
+				*state, *queued = qYes, false
+			} else {
+				*state = qNo
+			}
+		case qWantYes:
+			if *queued {
+				*state, *queued, reply = qWantNo, false, no
+			} else {
+				*state = qYes
+			}
+		}
+	} else {
+		switch *state {
+		case qYes:
+			*state, reply = qNo, no
+		case qWantNo:
+			if *queued {
+				*state, *queued, reply = qWantYes, false, yes
+			} else {
+				*state = qNo
+			}
+		case qWantYes:
+			*state, *queued = qNo, false
+		}
+	}
+
+	hooks := t.opts.changed(opt, o, remote, was)
+	t.opts.Unlock()
+
+	if reply != 0 {
+		t.SendCommand(reply, opt)
+	}
+	hooks()
+}
+
+// changed returns a function that runs the option's callback and the hooks
+// if one side of opt was enabled or disabled, i.e. if whether it is in the
+// qYes state is no longer the same as was. The caller must hold the lock,
+// and run the function after releasing it.
+func (ot *optionTable) changed(opt tnSeq, o *option, remote, was bool) func() {
+	state := o.us
+	if remote {
+		state = o.him
+	}
+	now := state == qYes
+	if now == was {
+		return func() {}
+	}
+
+	enabled := o.enabled
+	hooks := append([]OptionHook(nil), ot.hooks...)
+	return func() {
+		if now && enabled != nil {
+			enabled()
+		}
+		for _, h := range hooks {
+			h(opt, remote, now)
+		}
+	}
+}
+
+// subnegotiate handles the data of a finished subnegotiation for opt.
+func (t *Conn) subnegotiate(opt tnSeq, data []byte) {
+	t.opts.Lock()
+	o, ok := t.opts.options[opt]
+	t.opts.Unlock()
+	if ok && o.sub != nil {
+		o.sub(data)
+	}
+}
+
+// SetWindowSize changes the window size reported to the server, and sends
+// it right away if the server has asked for it.
+func (t *Conn) SetWindowSize(width, height int) error {
+	t.opts.Lock()
+	t.opts.width, t.opts.height = width, height
+	on := t.opts.get(WS).us == qYes
+	t.opts.Unlock()
+
+	if !on {
+		return nil
+	}
+	return t.sendWindowSize()
+}
+
+func (t *Conn) sendWindowSize() error {
+	t.opts.Lock()
+	w, h := t.opts.width, t.opts.height
+	t.opts.Unlock()
+
+	return t.sendSub(WS, []byte{byte(w >> 8), byte(w), byte(h >> 8), byte(h)})
+}
+
+func (t *Conn) sendTerminalType(data []byte) {
+	if len(data) == 0 || data[0] != ttypeSEND {
+		return
+	}
+
+	t.opts.Lock()
+	name := t.opts.ttypes[t.opts.ttype]
+	if t.opts.ttype < len(t.opts.ttypes)-1 {
+		t.opts.ttype++
+	}
+	t.opts.Unlock()
+
+	t.sendSub(TT, append([]byte{ttypeIS}, name...))
+}
+
+// sendSub sends a subnegotiation for opt, escaping any IAC in data.
+func (t *Conn) sendSub(opt tnSeq, data []byte) error {
+	var msg bytes.Buffer
+	msg.Write([]byte{byte(IAC), byte(SB), byte(opt)})
+	msg.Write(bytes.ReplaceAll(data, []byte{byte(IAC)}, []byte{byte(IAC), byte(IAC)}))
+	msg.Write([]byte{byte(IAC), byte(SE)})
+	_, err := t.Write(msg.Bytes())
+	return err
+}
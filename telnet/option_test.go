@@ -0,0 +1,109 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNegotiation(t *testing.T) {
+	sb := func(opt tnSeq, data string) []byte {
+		return append(append([]byte{byte(IAC), byte(SB), byte(opt)}, data...), byte(IAC), byte(SE))
+	}
+	cmd := func(verb, opt tnSeq) []byte {
+		return []byte{byte(IAC), byte(verb), byte(opt)}
+	}
+	cfg := &Config{
+		Client:       "test",
+		Width:        120,
+		Height:       255,
+		TerminalType: "XTERM-256COLOR",
+		MTTS:         MTTSANSI | MTTSUTF8,
+	}
+
+	tests := map[string]struct {
+		setup func(*Conn)
+		send  [][]byte
+		reply [][]byte
+	}{
+		"naws": {
+			send: [][]byte{cmd(DO, WS)},
+			reply: [][]byte{
+				cmd(WILL, WS),
+				{byte(IAC), byte(SB), byte(WS), 0, 120, 0, 255, 255, byte(IAC), byte(SE)},
+			},
+		},
+		"naws again": {
+			send:  [][]byte{cmd(DO, WS), cmd(DO, WS), cmd(DONT, WS)},
+			reply: [][]byte{cmd(WILL, WS), {byte(IAC), byte(SB), byte(WS), 0, 120, 0, 255, 255, byte(IAC), byte(SE)}, cmd(WONT, WS)},
+		},
+		"ttype": {
+			send: [][]byte{
+				cmd(DO, TT),
+				sb(TT, "\x01"), sb(TT, "\x01"), sb(TT, "\x01"), sb(TT, "\x01"),
+			},
+			reply: [][]byte{
+				cmd(WILL, TT),
+				sb(TT, "\x00TEST"), sb(TT, "\x00XTERM-256COLOR"), sb(TT, "\x00MTTS 5"), sb(TT, "\x00MTTS 5"),
+			},
+		},
+		"msdp": {
+			send:  [][]byte{cmd(WILL, MSDP)},
+			reply: [][]byte{cmd(DO, MSDP)},
+		},
+		"refuse": {
+			send:  [][]byte{cmd(WILL, ECHO), cmd(DO, ECHO), cmd(WILL, 0x99)},
+			reply: [][]byte{cmd(DONT, ECHO), cmd(WONT, ECHO), cmd(DONT, 0x99)},
+		},
+		"ignore disable": {
+			send:  [][]byte{cmd(WONT, ECHO), cmd(DONT, ECHO), cmd(WILL, SGA)},
+			reply: [][]byte{cmd(DONT, SGA)},
+		},
+		"supported": {
+			setup: func(c *Conn) { c.SupportRemote(SGA, true) },
+			send:  [][]byte{cmd(WILL, SGA), cmd(WILL, SGA), cmd(WONT, SGA)},
+			reply: [][]byte{cmd(DO, SGA), cmd(DONT, SGA)},
+		},
+		"request": {
+			setup: func(c *Conn) {
+				c.EnableRemote(SGA)
+				c.EnableLocal(ECHO)
+			},
+			// Acknowledgements of our own requests are not answered. The
+			// last refusal shows that nothing else was sent.
+			send:  [][]byte{cmd(WILL, SGA), cmd(DO, ECHO), cmd(WILL, SGA), cmd(WILL, 0x99)},
+			reply: [][]byte{cmd(DO, SGA), cmd(WILL, ECHO), cmd(DONT, 0x99)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			defer client.Close()
+			conn := newConn(client, cfg)
+
+			go func() {
+				if test.setup != nil {
+					test.setup(conn)
+				}
+				for _, b := range test.send {
+					conn.processor.processBytes(b)
+				}
+			}()
+
+			server.SetReadDeadline(time.Now().Add(time.Second))
+			for _, want := range test.reply {
+				got := make([]byte, len(want))
+				if _, err := io.ReadFull(server, got); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("got reply %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
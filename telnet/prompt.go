@@ -0,0 +1,120 @@
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/acarl005/stripansi"
+)
+
+// A PromptMode is a way of recognizing prompts. In every mode, a prompt
+// followed by GA, or by EOR once the server has enabled the EOR option, is
+// recognized.
+type PromptMode int
+
+const (
+	// PromptGA recognizes only prompts followed by GA or EOR.
+	PromptGA PromptMode = iota
+	// PromptTimeout also takes a line to be a prompt if the rest of it
+	// doesn't arrive within Config.PromptTimeout.
+	PromptTimeout
+	// PromptRegexp also takes a line to be a prompt as soon as it matches
+	// Config.PromptPattern.
+	PromptRegexp
+)
+
+// DefaultPromptTimeout is used when Config.PromptTimeout is zero.
+const DefaultPromptTimeout = 250 * time.Millisecond
+
+// maxPromptLen is the number of bytes of a partial line kept for matching
+// against Config.PromptPattern.
+const maxPromptLen = 512
+
+// trackLine keeps the part of the current line read so far.
+func (t *Conn) trackLine(b []byte) {
+	if i := bytes.LastIndexAny(b, "\n\x04"); i >= 0 {
+		t.line = append(t.line[:0], b[i+1:]...)
+	} else {
+		t.line = append(t.line, b...)
+	}
+	if len(t.line) > maxPromptLen {
+		t.line = append(t.line[:0], t.line[len(t.line)-maxPromptLen:]...)
+	}
+}
+
+// atPrompt reports whether all the data read so far ends in a partial line
+// matching the prompt pattern, ignoring ANSI escape sequences.
+func (t *Conn) atPrompt() bool {
+	if t.cfg.PromptPattern == nil || len(t.line) == 0 || len(t.processor.cleanBytes) > 0 {
+		return false
+	}
+	line := stripansi.Strip(string(bytes.TrimRight(t.line, "\r")))
+	return t.cfg.PromptPattern.MatchString(line)
+}
+
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// pump reads from the server in the background, so that readTimeout can stop
+// waiting for the rest of a line.
+func (t *Conn) pump() {
+	for {
+		buf := make([]byte, 4096)
+		n, err := t.read(buf)
+		select {
+		case t.chunks <- chunk{data: buf[:n], err: err}:
+		case <-t.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readTimeout is Read for PromptTimeout mode. A partial line is followed by
+// a prompt marker if nothing more arrives within the timeout.
+func (t *Conn) readTimeout(b []byte) (int, error) {
+	t.pumpOnce.Do(func() {
+		t.chunks = make(chan chunk)
+		go t.pump()
+	})
+
+	if len(t.pending) == 0 {
+		if t.pendingErr != nil {
+			return 0, t.pendingErr
+		}
+		select {
+		case c := <-t.chunks:
+			t.pending, t.pendingErr = c.data, c.err
+		case <-t.done:
+			return 0, net.ErrClosed
+		}
+	}
+
+	n := copy(b, t.pending)
+	t.pending = t.pending[n:]
+	if n == 0 {
+		return 0, t.pendingErr
+	}
+	if len(t.pending) > 0 || t.pendingErr != nil {
+		return n, nil
+	}
+	if last := b[n-1]; last == '\n' || last == '\x04' {
+		return n, nil
+	}
+
+	timer := time.NewTimer(t.cfg.PromptTimeout)
+	defer timer.Stop()
+	select {
+	case c := <-t.chunks:
+		t.pending, t.pendingErr = c.data, c.err
+	case <-timer.C:
+		t.pending = []byte{'\x04'}
+	case <-t.done:
+	}
+	return n, nil
+}
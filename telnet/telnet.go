@@ -59,6 +59,7 @@ const (
 	RFC  tnSeq = 0x21 // Remote flow control
 	LM   tnSeq = 0x22 // Line mode
 	EV   tnSeq = 0x24 // Environment variables
+	EORC tnSeq = 0xEF // End of record marker, sent after prompts once EOR is enabled
 	SE   tnSeq = 0xF0 // End of subnegotiation
 	NOP  tnSeq = 0xF1 // No operation
 	DM   tnSeq = 0xF2 // Data mark. The data stream portion of a Synch. This should always be accompanied by a TCP Urgent notification.
@@ -77,6 +78,7 @@ const (
 	IAC  tnSeq = 0xFF // Interpret As Command
 	CMP1 tnSeq = 0x55 // MCCP Compress
 	CMP2 tnSeq = 0x56 // MCCP Compress2
+	MSDP tnSeq = 0x45 // Mud Server Data Protocol
 	ATCP tnSeq = 0xC8 // Achaea Telnet Client Protocol
 	GMCP tnSeq = 0xC9 // Generic MUD Communication Protocol
 )
@@ -104,12 +106,14 @@ type tnProcessor struct {
 	state tnState
 	echo  bool
 
-	buf [8]byte
-
 	currentSub  byte
 	subData     map[byte][]byte
 	cappedBytes []byte
 	cleanBytes  []byte
+
+	// compressing is set once the server starts an MCCP2 stream. Every
+	// byte after that point must be decompressed before it is processed.
+	compressing bool
 }
 
 func newTelnetProcessor() *tnProcessor {
@@ -157,24 +161,26 @@ func (p *tnProcessor) capSubData(d byte, b byte) {
 }
 
 func (p *tnProcessor) doHandlers(bs []byte) {
-	for i, h := range p.conn.handlers {
-		m := p.buf[:len(bs)]
-		copy(m, bs)
+	for _, h := range p.conn.handlers {
+		// Each handler runs in its own goroutine, so each gets its own copy.
+		m := append([]byte(nil), bs...)
 		err := h.send(m)
 		if err != nil {
-			go func() {
-				p.conn.handlerMutex.Lock()
-				defer p.conn.handlerMutex.Unlock()
-				p.conn.handlers = append(p.conn.handlers[:i], p.conn.handlers[i+1:]...)
-			}()
+			go p.conn.removeHandler(h)
 		}
 	}
 }
 
-func (p *tnProcessor) processBytes(bytes []byte) {
-	for _, b := range bytes {
+// processBytes processes bytes until they run out or the server starts
+// compressing the stream, and returns the number of bytes processed.
+func (p *tnProcessor) processBytes(bytes []byte) int {
+	for i, b := range bytes {
 		p.processByte(b)
+		if p.compressing {
+			return i + 1
+		}
 	}
+	return len(bytes)
 }
 
 func (p *tnProcessor) processByte(b byte) {
@@ -197,12 +203,13 @@ func (p *tnProcessor) processByte(b byte) {
 		} else {
 			p.state = inDefault
 		}
-		if bs == GA {
+		if bs == GA || bs == EORC && p.conn.RemoteEnabled(EOR) {
 			p.dontCap('\x04')
 		}
 		p.capture(b)
 
 		if p.state == inDefault { // This means a command is over!
+			p.negotiate(p.cappedBytes)
 			p.doHandlers(p.cappedBytes)
 			p.cappedBytes = []byte{}
 		}
@@ -230,11 +237,25 @@ func (p *tnProcessor) processByte(b byte) {
 			p.state = inDefault
 			p.processByte(byte(IAC))
 			p.processByte(b)
+
+			// IAC SB COMPRESS2 IAC SE: everything after this is compressed.
+			if p.currentSub == byte(CMP2) && bs == SE {
+				p.compressing = true
+			}
 		}
 	}
 }
 
+// negotiate answers option negotiation. This has to happen in step with the
+// data stream, rather than in a handler goroutine, so that e.g. compression
+// starts at the right byte.
+func (p *tnProcessor) negotiate(cmd []byte) {
+	if len(cmd) == 3 {
+		p.conn.negotiate(tnSeq(cmd[1]), tnSeq(cmd[2]))
+	}
+}
+
 func (p *tnProcessor) subDataFinished(d byte) {
+	p.conn.subnegotiate(tnSeq(d), p.subData[d])
 	p.doHandlers(append([]byte{d}, p.subData[d]...))
-	// Probably where we should handle subdata type handlers. GMCP, etc.
 }
@@ -5,29 +5,31 @@ package telnet
 import "fmt"
 
 const (
-	_tnSeq_name_0 = "NULECHO"
-	_tnSeq_name_1 = "SGA"
-	_tnSeq_name_2 = "STTMBELBSHTLF"
-	_tnSeq_name_3 = "FFCR"
-	_tnSeq_name_4 = "TTEOR"
-	_tnSeq_name_5 = "WSTSRFCLM"
-	_tnSeq_name_6 = "EV"
-	_tnSeq_name_7 = "CMP1CMP2"
-	_tnSeq_name_8 = "ATCPGMCP"
-	_tnSeq_name_9 = "SENOPDMBRKIPAOAYTECELGASBWILLWONTDODONTIAC"
+	_tnSeq_name_0  = "NULECHO"
+	_tnSeq_name_1  = "SGA"
+	_tnSeq_name_2  = "STTMBELBSHTLF"
+	_tnSeq_name_3  = "FFCR"
+	_tnSeq_name_4  = "TTEOR"
+	_tnSeq_name_5  = "WSTSRFCLM"
+	_tnSeq_name_6  = "EV"
+	_tnSeq_name_7  = "MSDP"
+	_tnSeq_name_8  = "CMP1CMP2"
+	_tnSeq_name_9  = "ATCPGMCP"
+	_tnSeq_name_10 = "EORCSENOPDMBRKIPAOAYTECELGASBWILLWONTDODONTIAC"
 )
 
 var (
-	_tnSeq_index_0 = [...]uint8{0, 3, 7}
-	_tnSeq_index_1 = [...]uint8{0, 3}
-	_tnSeq_index_2 = [...]uint8{0, 2, 4, 7, 9, 11, 13}
-	_tnSeq_index_3 = [...]uint8{0, 2, 4}
-	_tnSeq_index_4 = [...]uint8{0, 2, 5}
-	_tnSeq_index_5 = [...]uint8{0, 2, 4, 7, 9}
-	_tnSeq_index_6 = [...]uint8{0, 2}
-	_tnSeq_index_7 = [...]uint8{0, 4, 8}
-	_tnSeq_index_8 = [...]uint8{0, 4, 8}
-	_tnSeq_index_9 = [...]uint8{0, 2, 5, 7, 10, 12, 14, 17, 19, 21, 23, 25, 29, 33, 35, 39, 42}
+	_tnSeq_index_0  = [...]uint8{0, 3, 7}
+	_tnSeq_index_1  = [...]uint8{0, 3}
+	_tnSeq_index_2  = [...]uint8{0, 2, 4, 7, 9, 11, 13}
+	_tnSeq_index_3  = [...]uint8{0, 2, 4}
+	_tnSeq_index_4  = [...]uint8{0, 2, 5}
+	_tnSeq_index_5  = [...]uint8{0, 2, 4, 7, 9}
+	_tnSeq_index_6  = [...]uint8{0, 2}
+	_tnSeq_index_7  = [...]uint8{0, 4}
+	_tnSeq_index_8  = [...]uint8{0, 4, 8}
+	_tnSeq_index_9  = [...]uint8{0, 4, 8}
+	_tnSeq_index_10 = [...]uint8{0, 4, 6, 9, 11, 14, 16, 18, 21, 23, 25, 27, 29, 33, 37, 39, 43, 46}
 )
 
 func (i tnSeq) String() string {
@@ -50,15 +52,17 @@ func (i tnSeq) String() string {
 		return _tnSeq_name_5[_tnSeq_index_5[i]:_tnSeq_index_5[i+1]]
 	case i == 36:
 		return _tnSeq_name_6
+	case i == 69:
+		return _tnSeq_name_7
 	case 85 <= i && i <= 86:
 		i -= 85
-		return _tnSeq_name_7[_tnSeq_index_7[i]:_tnSeq_index_7[i+1]]
+		return _tnSeq_name_8[_tnSeq_index_8[i]:_tnSeq_index_8[i+1]]
 	case 200 <= i && i <= 201:
 		i -= 200
-		return _tnSeq_name_8[_tnSeq_index_8[i]:_tnSeq_index_8[i+1]]
-	case 240 <= i && i <= 255:
-		i -= 240
 		return _tnSeq_name_9[_tnSeq_index_9[i]:_tnSeq_index_9[i+1]]
+	case 239 <= i && i <= 255:
+		i -= 239
+		return _tnSeq_name_10[_tnSeq_index_10[i]:_tnSeq_index_10[i+1]]
 	default:
 		return fmt.Sprintf("tnSeq(%d)", i)
 	}
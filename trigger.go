@@ -0,0 +1,269 @@
+package mud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A Trigger runs a command when a line of output matches its pattern.
+type Trigger struct {
+	Pattern Pattern `yaml:"match"`
+	Do      string
+	// Triggers with a higher priority are tried first. Triggers with the
+	// same priority are tried in the order they are listed.
+	Priority int `yaml:"priority,omitempty"`
+	// Stop keeps any later triggers from matching the same line.
+	Stop bool `yaml:"stop,omitempty"`
+	// Group is the name of a group of triggers, aliases, timers and
+	// highlights that can be enabled and disabled together.
+	Group string `yaml:"group,omitempty"`
+
+	// Lines, instead of Pattern, makes a trigger that matches a sequence
+	// of lines, one pattern per line. The capture groups of all the
+	// patterns are numbered in order, so that $1 is the first group of the
+	// first pattern. The lines must be consecutive, unless Within allows
+	// the whole sequence to span more lines than there are patterns.
+	Lines  []Pattern `yaml:"lines,omitempty"`
+	Within int       `yaml:"within,omitempty"`
+
+	// Assign sets variables from the named capture groups of the match.
+	Assign Assign `yaml:"assign,omitempty"`
+}
+
+// MaxWithin is the most lines a multi-line trigger can span.
+const MaxWithin = 100
+
+// Window returns the number of lines a multi-line trigger spans.
+func (t Trigger) Window() int {
+	if t.Within > len(t.Lines) {
+		return t.Within
+	}
+	return len(t.Lines)
+}
+
+// Sequence returns a pattern that matches the lines of a multi-line trigger,
+// joined by newlines, if the last of them matches its last pattern.
+func (t Trigger) Sequence() Pattern {
+	gap := `\n`
+	if t.Within > len(t.Lines) {
+		gap = `\n(?:.*\n)*?`
+	}
+	parts := make([]string, len(t.Lines))
+	for i, p := range t.Lines {
+		parts[i] = `^.*?(?:` + p.expr() + `).*$`
+	}
+	return Pattern(`(?m)` + strings.Join(parts, gap) + `\z`)
+}
+
+func (t Trigger) check() error {
+	if len(t.Lines) == 0 {
+		if t.Pattern == "" {
+			return fmt.Errorf("trigger has no match or lines")
+		}
+		return nil
+	}
+	switch {
+	case t.Pattern != "":
+		return fmt.Errorf("trigger has both match and lines")
+	case t.Within != 0 && t.Within < len(t.Lines):
+		return fmt.Errorf("trigger within %d lines has %d patterns", t.Within, len(t.Lines))
+	case t.Within > MaxWithin:
+		return fmt.Errorf("trigger within %d lines: at most %d", t.Within, MaxWithin)
+	}
+	return nil
+}
+
+// Triggers is a list of triggers in the order they are tried. It is written
+// either as a list of triggers, or as a mapping from patterns to commands,
+// tried in the order they are written.
+type Triggers []Trigger
+
+func (t *Triggers) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var triggers []Trigger
+	if isSequence(unmarshal) {
+		if err := unmarshal(&triggers); err != nil {
+			return err
+		}
+		for _, trigger := range triggers {
+			if err := trigger.check(); err != nil {
+				return err
+			}
+		}
+	} else {
+		var m map[Pattern]string
+		if err := unmarshal(&m); err != nil {
+			return err
+		}
+		keys, err := keyOrder(unmarshal)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			triggers = append(triggers, Trigger{Pattern: key, Do: m[key]})
+		}
+	}
+	sort.SliceStable(triggers, func(i, j int) bool {
+		return triggers[i].Priority > triggers[j].Priority
+	})
+	*t = triggers
+	return nil
+}
+
+// A Highlight colors the parts of a line matching its pattern.
+type Highlight struct {
+	Pattern Pattern `yaml:"match"`
+	Color   *Color
+	Group   string `yaml:"group,omitempty"`
+}
+
+// Highlights is a list of highlights in the order they are applied, written
+// either as a list or as a mapping from patterns to colors.
+type Highlights []Highlight
+
+func (h *Highlights) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if isSequence(unmarshal) {
+		var list []Highlight
+		if err := unmarshal(&list); err != nil {
+			return err
+		}
+		*h = list
+		return nil
+	}
+	var m map[Pattern]*Color
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	keys, err := keyOrder(unmarshal)
+	if err != nil {
+		return err
+	}
+	*h = nil
+	for _, key := range keys {
+		*h = append(*h, Highlight{Pattern: key, Color: m[key]})
+	}
+	return nil
+}
+
+// A Replacement replaces a line matching its pattern with the expansion of
+// With, in Color if it is set.
+type Replacement struct {
+	Pattern Pattern `yaml:"match"`
+	With    string
+	Color   *Color
+	Assign  Assign `yaml:"assign,omitempty"`
+}
+
+// Replacements is a list of replacements in the order they are applied,
+// written either as a list or as a mapping from patterns to replacements.
+type Replacements []Replacement
+
+func (r *Replacements) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if isSequence(unmarshal) {
+		var list []Replacement
+		if err := unmarshal(&list); err != nil {
+			return err
+		}
+		*r = list
+		return nil
+	}
+	var m map[Pattern]struct {
+		With   string
+		Color  *Color
+		Assign Assign
+	}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	keys, err := keyOrder(unmarshal)
+	if err != nil {
+		return err
+	}
+	*r = nil
+	for _, key := range keys {
+		v := m[key]
+		*r = append(*r, Replacement{Pattern: key, With: v.With, Color: v.Color, Assign: v.Assign})
+	}
+	return nil
+}
+
+// Assign says which named capture groups of a pattern set variables. It is
+// written either as true, to set a variable of the same name from every
+// named group, or as a mapping from group names to variable names.
+type Assign struct {
+	All    bool
+	Groups map[string]string
+}
+
+func (a *Assign) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&a.All); err == nil {
+		return nil
+	}
+	return unmarshal(&a.Groups)
+}
+
+// Vars returns the variables to set, given the values of the named capture
+// groups of a match.
+func (a Assign) Vars(captures map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for group, value := range captures {
+		if a.All {
+			vars[group] = value
+		} else if name, ok := a.Groups[group]; ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// isSequence reports whether the value being unmarshaled is a list (or
+// empty), rather than a mapping.
+func isSequence(unmarshal func(interface{}) error) bool {
+	var list []interface{}
+	return unmarshal(&list) == nil
+}
+
+// keyOrder returns the keys of the mapping being unmarshaled, in the order
+// they are written. Keys that YAML resolves to booleans, numbers or null are
+// returned as written, as they are when unmarshaled into a map[Pattern].
+//
+// yaml.v2 only gives the order of the resolved keys, so keys written
+// differently that resolve to the same value, such as "yes" and "true", can't
+// be told apart; they are returned in sorted order wherever they appear.
+func keyOrder(unmarshal func(interface{}) error) ([]Pattern, error) {
+	var items yaml.MapSlice
+	if err := unmarshal(&items); err != nil {
+		return nil, err
+	}
+	var m map[Pattern]interface{}
+	if err := unmarshal(&m); err != nil {
+		return nil, err
+	}
+
+	// the text of each key that isn't a string, by the value it resolves to
+	texts := make(map[interface{}][]Pattern)
+	for _, text := range sortedKeys(m) {
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(text), &v); err != nil {
+			continue
+		}
+		switch v.(type) {
+		case nil, bool, int, int64, uint64, float64:
+			texts[v] = append(texts[v], Pattern(text))
+		}
+	}
+
+	keys := make([]Pattern, len(items))
+	for i, item := range items {
+		if s, ok := item.Key.(string); ok {
+			keys[i] = Pattern(s)
+		} else if t := texts[item.Key]; len(t) > 0 {
+			keys[i], texts[item.Key] = t[0], t[1:]
+		} else {
+			keys[i] = Pattern(fmt.Sprint(item.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,215 @@
+package mud
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTriggers(t *testing.T) {
+	tests := map[string]struct {
+		yaml string
+		want Triggers
+	}{
+		"map": {
+			yaml: "b: one\na: two\nc: three\n",
+			want: Triggers{
+				{Pattern: "b", Do: "one"},
+				{Pattern: "a", Do: "two"},
+				{Pattern: "c", Do: "three"},
+			},
+		},
+		"list": {
+			yaml: "- match: b\n  do: one\n- match: a\n  do: two\n  stop: true\n",
+			want: Triggers{
+				{Pattern: "b", Do: "one"},
+				{Pattern: "a", Do: "two", Stop: true},
+			},
+		},
+		"priority": {
+			yaml: "- match: a\n  do: one\n- match: b\n  do: two\n  priority: 10\n- match: c\n  do: three\n- match: d\n  do: four\n  priority: -1\n",
+			want: Triggers{
+				{Pattern: "b", Do: "two", Priority: 10},
+				{Pattern: "a", Do: "one"},
+				{Pattern: "c", Do: "three"},
+				{Pattern: "d", Do: "four", Priority: -1},
+			},
+		},
+		"numeric pattern": {
+			yaml: "10: one\n",
+			want: Triggers{{Pattern: "10", Do: "one"}},
+		},
+		"boolean pattern": {
+			yaml: "yes: one\nno: two\n",
+			want: Triggers{{Pattern: "yes", Do: "one"}, {Pattern: "no", Do: "two"}},
+		},
+		"float pattern": {
+			yaml: "1.0: one\n1: two\n",
+			want: Triggers{{Pattern: "1.0", Do: "one"}, {Pattern: "1", Do: "two"}},
+		},
+		"same value": {
+			// a known limitation: keys that resolve to the same value are
+			// sorted, rather than kept in the order they are written
+			yaml: "yes: one\nfoo: two\ntrue: three\n",
+			want: Triggers{{Pattern: "true", Do: "three"}, {Pattern: "foo", Do: "two"}, {Pattern: "yes", Do: "one"}},
+		},
+		"null pattern": {
+			yaml: "~: one\n",
+			want: Triggers{{Pattern: "", Do: "one"}},
+		},
+		"empty": {
+			yaml: "",
+			want: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got Triggers
+			if err := yaml.Unmarshal([]byte(test.yaml), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("triggers mismatch: %v", diff)
+			}
+		})
+	}
+}
+
+func TestHighlightOrder(t *testing.T) {
+	var cfg struct {
+		Highlight Highlights
+		Replace   Replacements
+	}
+	data := "highlight:\n  z: red\n  a: blue\n  m: green\nreplace:\n  z:\n    with: Z\n  a:\n    with: A\n    color: red\n"
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var highlights []Pattern
+	for _, h := range cfg.Highlight {
+		if h.Color == nil {
+			t.Errorf("highlight %q has no color", h.Pattern)
+		}
+		highlights = append(highlights, h.Pattern)
+	}
+	if diff := cmp.Diff([]Pattern{"z", "a", "m"}, highlights); diff != "" {
+		t.Errorf("highlight order mismatch: %v", diff)
+	}
+
+	var replacements []string
+	for _, r := range cfg.Replace {
+		replacements = append(replacements, string(r.Pattern)+"="+r.With)
+	}
+	if diff := cmp.Diff([]string{"z=Z", "a=A"}, replacements); diff != "" {
+		t.Errorf("replace order mismatch: %v", diff)
+	}
+	if cfg.Replace[1].Color == nil {
+		t.Errorf("replacement %q has no color", cfg.Replace[1].Pattern)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	tests := map[string]struct {
+		trigger Trigger
+		lines   string
+		want    string
+	}{
+		"consecutive": {
+			trigger: Trigger{Lines: []Pattern{`(\w+) arrives from the (\w+)`, `(\w+) attacks you!`}, Do: "$1 $2 $3"},
+			lines:   "An orc arrives from the north.\nThe orc attacks you!",
+			want:    "orc north orc",
+		},
+		"not consecutive": {
+			trigger: Trigger{Lines: []Pattern{`arrives`, `attacks you`}, Do: "x"},
+			lines:   "An orc arrives from the north.\nThe orc grins.\nThe orc attacks you!",
+		},
+		"within": {
+			trigger: Trigger{Lines: []Pattern{`(\w+) arrives`, `(\w+) attacks you`}, Within: 3, Do: "$1 $2"},
+			lines:   "An orc arrives from the north.\nThe orc grins.\nThe orc attacks you!",
+			want:    "orc orc",
+		},
+		"last line": {
+			trigger: Trigger{Lines: []Pattern{`arrives`, `attacks you`}, Within: 3, Do: "x"},
+			lines:   "An orc arrives from the north.\nThe orc attacks you!\nThe orc grins.",
+		},
+		"anchored": {
+			trigger: Trigger{Lines: []Pattern{`^(\w+) arrives`, `^Hi$`}, Do: "$1"},
+			lines:   "Bob arrives.\nHi",
+			want:    "Bob",
+		},
+		"anchored mismatch": {
+			trigger: Trigger{Lines: []Pattern{`^arrives`, `^Hi$`}, Do: "x"},
+			lines:   "Bob arrives.\nHi",
+		},
+		"kinds": {
+			trigger: Trigger{Lines: []Pattern{`glob:* arrives.`, `literal:(you)`}, Do: "$1"},
+			lines:   "Bob arrives.\nHe attacks (you)!",
+			want:    "Bob",
+		},
+		"named": {
+			trigger: Trigger{Lines: []Pattern{`(?P<who>\w+) arrives`, `(\d+) gold`}, Do: "${who} $2"},
+			lines:   "Bob arrives.\nHe drops 12 gold.",
+			want:    "Bob 12",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			seq := test.trigger.Sequence()
+			lines := []byte(test.lines)
+			if got := seq.Match(lines); got != (test.want != "") {
+				t.Fatalf("Match = %v, want %v", got, test.want != "")
+			}
+			if test.want == "" {
+				return
+			}
+			if got := seq.Expand(lines, test.trigger.Do); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTriggerErrors(t *testing.T) {
+	tests := map[string]string{
+		"match and lines": "- match: a\n  lines: [b, c]\n  do: x\n",
+		"within too few":  "- lines: [a, b, c]\n  within: 2\n  do: x\n",
+		"within too many": "- lines: [a, b]\n  within: 1000\n  do: x\n",
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var triggers Triggers
+			if err := yaml.Unmarshal([]byte(data), &triggers); err == nil {
+				t.Errorf("got no error for %q", data)
+			}
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	captures := map[string]string{"tank": "Bob", "hp": "10"}
+	tests := map[string]struct {
+		yaml string
+		want map[string]string
+	}{
+		"all":     {yaml: "assign: true", want: map[string]string{"tank": "Bob", "hp": "10"}},
+		"none":    {yaml: "assign: false", want: map[string]string{}},
+		"mapping": {yaml: "assign: {tank: maintank}", want: map[string]string{"maintank": "Bob"}},
+		"missing": {yaml: "do: x", want: map[string]string{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var trigger Trigger
+			if err := yaml.Unmarshal([]byte(test.yaml), &trigger); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, trigger.Assign.Vars(captures)); diff != "" {
+				t.Errorf("vars mismatch: %v", diff)
+			}
+		})
+	}
+}